@@ -18,6 +18,8 @@ var Structures = []StructureInfo{
 	{Name: pkg.Bounty, Type: "stream"},
 	{Name: pkg.SolutionMerge, Type: "stream"},
 	{Name: pkg.LiveUpdates, Type: "stream"},
+	{Name: pkg.DeadLetters, Type: "stream"},
+	{Name: pkg.CommandRejections, Type: "stream"},
 
 	// HashSets
 	{Name: pkg.BugSet, Type: "hash"},
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"time"
 
+	"github.com/IAmRiteshKoushik/alfred/pkg"
 	v "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/go-ozzo/ozzo-validation/v4/is"
 	"github.com/spf13/viper"
@@ -12,6 +14,14 @@ import (
 
 var AppConfig *EnvConfig
 
+// StreamRetention bounds how much of a stream is kept in Valkey. Entries are
+// trimmed once the stream is longer than MaxLen or older than MaxAge, zero
+// disables either limit.
+type StreamRetention struct {
+	MaxLen int64
+	MaxAge time.Duration
+}
+
 type EnvConfig struct {
 	Environment string
 	ServerHost  string
@@ -19,6 +29,100 @@ type EnvConfig struct {
 	ValkeyHost  string
 	ValkeyPort  int
 	DatabaseURL string
+
+	// Logs are JSON in production and human readable in development unless
+	// log.format says otherwise.
+	Log pkg.LoggerConfig
+
+	// How long a shutdown waits for in-flight requests and background
+	// workers to finish before the connections are closed regardless.
+	ShutdownTimeout time.Duration
+
+	// Secrets used to verify the X-Hub-Signature-256 header on incoming
+	// webhooks. More than one secret can be configured while a secret is
+	// being rotated on GitHub, a delivery is accepted if any one matches.
+	WebhookSecrets []string
+
+	// How long a X-GitHub-Delivery id is remembered for rejecting duplicate
+	// deliveries. GitHub only allows redelivery of the past 3 days.
+	DeliveryTTL time.Duration
+
+	// Days a participant has to solve an issue after claiming it, by the
+	// difficulty label of the issue
+	ClaimDays map[string]int
+
+	// Active claims a participant may hold at once, in total and by the
+	// difficulty of the issue. Zero means no limit.
+	MaxActiveClaims             int
+	MaxActiveClaimsByDifficulty map[string]int
+
+	// Limits on /extend. Participants can extend a claim at most
+	// MaxExtensions times and by at most MaxExtensionDays in total, anything
+	// beyond needs a maintainer to approve it.
+	MaxExtensions    int
+	MaxExtensionDays int
+
+	// Claims past their deadline are released every ClaimSweepInterval.
+	// Participants are reminded ClaimReminderBefore the deadline, zero turns
+	// the reminders off.
+	ClaimSweepInterval  time.Duration
+	ClaimReminderBefore time.Duration
+
+	// An assignment passed on to DevPool holds the issue and counts towards
+	// the claim limits for PendingClaimTimeout, or until GitHub reports it.
+	PendingClaimTimeout time.Duration
+
+	// A maintainer can /undo the last bounty, penalty or badge they handed
+	// out on an issue for this long.
+	UndoWindow time.Duration
+
+	// Background processing of queued deliveries. Deliveries for the same
+	// issue or pull request always land on the same worker.
+	QueueWorkers      int
+	QueueMaxAttempts  int
+	QueueBaseBackoff  time.Duration
+	QueueMaxBackoff   time.Duration
+	QueuePollInterval time.Duration
+
+	// Attempts at publishing an outbox entry before it is moved to the
+	// dead letters. Retries back off like the delivery queue.
+	OutboxMaxAttempts int
+
+	// Readiness reports the outbox or queue as lagging once their oldest
+	// pending entry is older than this.
+	MaxOutboxLag time.Duration
+	MaxQueueLag  time.Duration
+
+	// OTLP/HTTP endpoint traces are exported to, such as
+	// http://localhost:4318. Traces are not exported when empty.
+	TracingEndpoint    string
+	TracingSampleRatio float64
+
+	// Retention applied to every stream unless overridden per stream, and
+	// how often the trimmer enforces it.
+	RetentionInterval time.Duration
+	DefaultRetention  StreamRetention
+	StreamRetentions  map[string]StreamRetention
+}
+
+// RetentionFor returns the retention policy configured for a stream
+func (e *EnvConfig) RetentionFor(stream string) StreamRetention {
+	if r, ok := e.StreamRetentions[stream]; ok {
+		return r
+	}
+	return e.DefaultRetention
+}
+
+// Difficulty labels of an issue. Issues without one are treated as EASY.
+var Difficulties = []string{"EASY", "MEDIUM", "HARD"}
+
+// ClaimDaysFor returns how many days a claim on an issue of the given
+// difficulty lasts
+func (e *EnvConfig) ClaimDaysFor(difficulty string) int {
+	if days, ok := e.ClaimDays[strings.ToUpper(difficulty)]; ok {
+		return days
+	}
+	return e.ClaimDays["EASY"]
 }
 
 // isValidHost must satisfy the following interface to be accepted as a
@@ -49,6 +153,30 @@ func (e *EnvConfig) Validate() error {
 		v.Field(&e.ValkeyHost, v.Required, v.By(isValidHost)),
 		v.Field(&e.ValkeyPort, v.Required, v.Min(1), v.Max(65535)),
 		v.Field(&e.DatabaseURL, v.Required, is.URL),
+		v.Field(&e.Log),
+		v.Field(&e.ShutdownTimeout, v.Required, v.Min(time.Second)),
+		v.Field(&e.WebhookSecrets, v.Required, v.Each(v.Required)),
+		v.Field(&e.DeliveryTTL, v.Required, v.Min(time.Minute)),
+		v.Field(&e.ClaimDays, v.Required, v.Each(v.Required, v.Min(1))),
+		v.Field(&e.MaxActiveClaims, v.Min(0)),
+		v.Field(&e.MaxActiveClaimsByDifficulty, v.Each(v.Min(0))),
+		v.Field(&e.MaxExtensions, v.Min(0)),
+		v.Field(&e.MaxExtensionDays, v.Min(0)),
+		v.Field(&e.ClaimSweepInterval, v.Required, v.Min(time.Second)),
+		v.Field(&e.ClaimReminderBefore, v.Min(time.Duration(0))),
+		v.Field(&e.PendingClaimTimeout, v.Required, v.Min(time.Minute)),
+		v.Field(&e.UndoWindow, v.Required, v.Min(time.Minute)),
+		v.Field(&e.QueueWorkers, v.Required, v.Min(1)),
+		v.Field(&e.QueueMaxAttempts, v.Required, v.Min(1)),
+		v.Field(&e.QueueBaseBackoff, v.Required, v.Min(time.Millisecond)),
+		v.Field(&e.QueueMaxBackoff, v.Required, v.Min(e.QueueBaseBackoff)),
+		v.Field(&e.QueuePollInterval, v.Required, v.Min(100*time.Millisecond)),
+		v.Field(&e.OutboxMaxAttempts, v.Required, v.Min(1)),
+		v.Field(&e.MaxOutboxLag, v.Required, v.Min(time.Second)),
+		v.Field(&e.MaxQueueLag, v.Required, v.Min(time.Second)),
+		v.Field(&e.TracingEndpoint, is.URL),
+		v.Field(&e.TracingSampleRatio, v.Min(0.0), v.Max(1.0)),
+		v.Field(&e.RetentionInterval, v.Required, v.Min(time.Minute)),
 	)
 }
 
@@ -60,6 +188,35 @@ func SetupEnv() error {
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
 
+	viper.SetDefault("server.shutdown_timeout", "30s")
+	viper.SetDefault("log.level", "info")
+	viper.SetDefault("log.file", "app.log")
+	viper.SetDefault("log.max_size_mb", 100)
+	viper.SetDefault("log.max_age_days", 14)
+	viper.SetDefault("log.max_backups", 10)
+	viper.SetDefault("log.compress", true)
+	viper.SetDefault("github.delivery_ttl", "72h")
+	viper.SetDefault("claims.days.easy", 3)
+	viper.SetDefault("claims.days.medium", 7)
+	viper.SetDefault("claims.days.hard", 14)
+	viper.SetDefault("claims.max_active", 3)
+	viper.SetDefault("claims.max_extensions", 2)
+	viper.SetDefault("claims.max_extension_days", 7)
+	viper.SetDefault("claims.sweep_interval", "5m")
+	viper.SetDefault("claims.reminder_before", "24h")
+	viper.SetDefault("claims.pending_timeout", "1h")
+	viper.SetDefault("commands.undo_window", "1h")
+	viper.SetDefault("queue.workers", 4)
+	viper.SetDefault("queue.max_attempts", 5)
+	viper.SetDefault("queue.base_backoff", "2s")
+	viper.SetDefault("queue.max_backoff", "2m")
+	viper.SetDefault("queue.poll_interval", "5s")
+	viper.SetDefault("outbox.max_attempts", 10)
+	viper.SetDefault("health.max_outbox_lag", "1m")
+	viper.SetDefault("health.max_queue_lag", "5m")
+	viper.SetDefault("tracing.sample_ratio", 1.0)
+	viper.SetDefault("retention.interval", "10m")
+
 	err := viper.ReadInConfig()
 	if err != nil {
 		return err
@@ -72,6 +229,81 @@ func SetupEnv() error {
 		ValkeyHost:  viper.GetString("valkey.host"),
 		ValkeyPort:  viper.GetInt("valkey.port"),
 		DatabaseURL: viper.GetString("database.url"),
+
+		ShutdownTimeout: viper.GetDuration("server.shutdown_timeout"),
+
+		Log: pkg.LoggerConfig{
+			Level:      viper.GetString("log.level"),
+			Format:     viper.GetString("log.format"),
+			Outputs:    viper.GetStringSlice("log.outputs"),
+			File:       viper.GetString("log.file"),
+			MaxSizeMB:  viper.GetInt("log.max_size_mb"),
+			MaxAgeDays: viper.GetInt("log.max_age_days"),
+			MaxBackups: viper.GetInt("log.max_backups"),
+			Compress:   viper.GetBool("log.compress"),
+		},
+
+		WebhookSecrets: viper.GetStringSlice("github.webhook_secrets"),
+		DeliveryTTL:    viper.GetDuration("github.delivery_ttl"),
+
+		MaxActiveClaims: viper.GetInt("claims.max_active"),
+
+		MaxExtensions:    viper.GetInt("claims.max_extensions"),
+		MaxExtensionDays: viper.GetInt("claims.max_extension_days"),
+
+		ClaimSweepInterval:  viper.GetDuration("claims.sweep_interval"),
+		ClaimReminderBefore: viper.GetDuration("claims.reminder_before"),
+		PendingClaimTimeout: viper.GetDuration("claims.pending_timeout"),
+
+		UndoWindow: viper.GetDuration("commands.undo_window"),
+
+		QueueWorkers:      viper.GetInt("queue.workers"),
+		QueueMaxAttempts:  viper.GetInt("queue.max_attempts"),
+		QueueBaseBackoff:  viper.GetDuration("queue.base_backoff"),
+		QueueMaxBackoff:   viper.GetDuration("queue.max_backoff"),
+		QueuePollInterval: viper.GetDuration("queue.poll_interval"),
+
+		OutboxMaxAttempts: viper.GetInt("outbox.max_attempts"),
+
+		MaxOutboxLag: viper.GetDuration("health.max_outbox_lag"),
+		MaxQueueLag:  viper.GetDuration("health.max_queue_lag"),
+
+		TracingEndpoint:    viper.GetString("tracing.endpoint"),
+		TracingSampleRatio: viper.GetFloat64("tracing.sample_ratio"),
+
+		RetentionInterval: viper.GetDuration("retention.interval"),
+		DefaultRetention: StreamRetention{
+			MaxLen: viper.GetInt64("retention.max_len"),
+			MaxAge: viper.GetDuration("retention.max_age"),
+		},
+		StreamRetentions: map[string]StreamRetention{},
+	}
+	AppConfig.ClaimDays = map[string]int{}
+	AppConfig.MaxActiveClaimsByDifficulty = map[string]int{}
+	for _, difficulty := range Difficulties {
+		key := strings.ToLower(difficulty)
+		AppConfig.ClaimDays[difficulty] = viper.GetInt("claims.days." + key)
+		AppConfig.MaxActiveClaimsByDifficulty[difficulty] = viper.GetInt("claims.max_active_by_difficulty." + key)
+	}
+	if AppConfig.Log.Format == "" {
+		AppConfig.Log.Format = pkg.LogConsole
+		if AppConfig.Environment == "production" {
+			AppConfig.Log.Format = pkg.LogJSON
+		}
+	}
+	if len(AppConfig.Log.Outputs) == 0 {
+		AppConfig.Log.Outputs = []string{pkg.LogStdout, pkg.LogFile}
+		if AppConfig.Environment == "production" {
+			AppConfig.Log.Outputs = []string{pkg.LogFile}
+		}
+	}
+	if streams := viper.Sub("retention.streams"); streams != nil {
+		for name := range streams.AllSettings() {
+			AppConfig.StreamRetentions[name] = StreamRetention{
+				MaxLen: streams.GetInt64(name + ".max_len"),
+				MaxAge: streams.GetDuration(name + ".max_age"),
+			}
+		}
 	}
 	if err := AppConfig.Validate(); err != nil {
 		return err
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// BeginTx starts a transaction on the pool whose duration is recorded when it
+// is committed or rolled back.
+func BeginTx(ctx context.Context) (pgx.Tx, error) {
+	tx, err := DBPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &timedTx{Tx: tx, start: time.Now()}, nil
+}
+
+type timedTx struct {
+	pgx.Tx
+	start time.Time
+	done  bool
+}
+
+func (t *timedTx) Commit(ctx context.Context) error {
+	err := t.Tx.Commit(ctx)
+	result := "commit"
+	if err != nil {
+		result = "error"
+	}
+	t.observe(result)
+	return err
+}
+
+// Rollback is deferred after every Begin, it is a no-op after a commit and is
+// not recorded a second time.
+func (t *timedTx) Rollback(ctx context.Context) error {
+	err := t.Tx.Rollback(ctx)
+	t.observe("rollback")
+	return err
+}
+
+func (t *timedTx) observe(result string) {
+	if t.done {
+		return
+	}
+	t.done = true
+	pkg.TxDuration.WithLabelValues(result).Observe(time.Since(t.start).Seconds())
+}
+
+var (
+	poolConnsDesc = prometheus.NewDesc("alfred_db_pool_conns",
+		"Connections in the Postgres pool by state.", []string{"state"}, nil)
+	poolMaxConnsDesc = prometheus.NewDesc("alfred_db_pool_max_conns",
+		"Maximum size of the Postgres pool.", nil, nil)
+	poolAcquiresDesc = prometheus.NewDesc("alfred_db_pool_acquires_total",
+		"Connections acquired from the Postgres pool.", nil, nil)
+	poolEmptyAcquiresDesc = prometheus.NewDesc("alfred_db_pool_empty_acquires_total",
+		"Acquires that had to wait because the pool was empty.", nil, nil)
+	poolAcquireSecondsDesc = prometheus.NewDesc("alfred_db_pool_acquire_seconds_total",
+		"Total time spent waiting to acquire a connection.", nil, nil)
+	streamLengthDesc = prometheus.NewDesc("alfred_stream_length",
+		"Number of entries in a Valkey stream.", []string{"stream"}, nil)
+)
+
+// PoolCollector reports pgxpool statistics at scrape time
+type PoolCollector struct{}
+
+func (PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolConnsDesc
+	ch <- poolMaxConnsDesc
+	ch <- poolAcquiresDesc
+	ch <- poolEmptyAcquiresDesc
+	ch <- poolAcquireSecondsDesc
+}
+
+func (PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := DBPool.Stat()
+	ch <- prometheus.MustNewConstMetric(poolConnsDesc, prometheus.GaugeValue,
+		float64(stat.AcquiredConns()), "acquired")
+	ch <- prometheus.MustNewConstMetric(poolConnsDesc, prometheus.GaugeValue,
+		float64(stat.IdleConns()), "idle")
+	ch <- prometheus.MustNewConstMetric(poolConnsDesc, prometheus.GaugeValue,
+		float64(stat.ConstructingConns()), "constructing")
+	ch <- prometheus.MustNewConstMetric(poolMaxConnsDesc, prometheus.GaugeValue,
+		float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquiresDesc, prometheus.CounterValue,
+		float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquiresDesc, prometheus.CounterValue,
+		float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireSecondsDesc, prometheus.CounterValue,
+		stat.AcquireDuration().Seconds())
+}
+
+// StreamCollector reports the length of each stream at scrape time
+type StreamCollector struct {
+	Client  *redis.Client
+	Streams []string
+}
+
+func (s StreamCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- streamLengthDesc
+}
+
+func (s StreamCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	pipe := s.Client.Pipeline()
+	lengths := make([]*redis.IntCmd, len(s.Streams))
+	for i, stream := range s.Streams {
+		lengths[i] = pipe.XLen(ctx, stream)
+	}
+	// Streams that could not be read are left out of the scrape
+	pipe.Exec(ctx)
+	for i, stream := range s.Streams {
+		if lengths[i].Err() != nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(streamLengthDesc, prometheus.GaugeValue,
+			float64(lengths[i].Val()), stream)
+	}
+}
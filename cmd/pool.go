@@ -24,6 +24,7 @@ func InitDB() (*pgxpool.Pool, error) {
 	config.MaxConnIdleTime = 1800
 	config.HealthCheckPeriod = 60
 	config.MaxConnLifetimeJitter = 0
+	config.ConnConfig.Tracer = queryTracer{}
 
 	pool, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
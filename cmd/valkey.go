@@ -3,9 +3,11 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -30,6 +32,12 @@ func InitValkey() (*redis.Client, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
+	// Every command is traced as a child of the span in its context
+	if err := redisotel.InstrumentTracing(rdb); err != nil {
+		pkg.Log.SetupFail("[FAIL]: Could not instrument Valkey client.", err)
+		return nil, err
+	}
+
 	pong, err := rdb.Ping(ctx).Result() // health-check
 	if err != nil {
 		pkg.Log.SetupFail("[FAIL]: Health-check failed for Valkey.", err)
@@ -47,17 +55,36 @@ func CloseValkey(client *redis.Client) {
 	}
 }
 
-func AddToStream(client *redis.Client, key string, value string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// Lowest entry id that has to be kept in each stream, as last computed by the
+// stream trimmer. It never passes an entry that a consumer group still needs.
+var streamMinIds sync.Map
+
+func SetStreamMinId(key string, id string) {
+	streamMinIds.Store(key, id)
+}
+
+// AddToStream appends an event to a stream. Once the trimmer has worked out a
+// safe retention boundary for the stream, older entries are also trimmed
+// (approximately) as part of the write.
+func AddToStream(ctx context.Context, client *redis.Client, key string, event pkg.Envelope) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	_, err := client.XAdd(ctx, &redis.XAddArgs{
+	args := &redis.XAddArgs{
 		Stream: key,
-		Values: map[string]string{"data": value},
-	}).Result()
+		Values: event.Fields(),
+	}
+	if minId, ok := streamMinIds.Load(key); ok {
+		args.MinID = minId.(string)
+		args.Approx = true
+	}
+	start := time.Now()
+	_, err := client.XAdd(ctx, args).Result()
 	if err != nil {
-		return fmt.Errorf("Failed to add to stream: %v", value)
+		pkg.XAddDuration.WithLabelValues(key, "error").Observe(time.Since(start).Seconds())
+		return fmt.Errorf("Failed to add %s event %s to stream: %v", event.Type, event.EventID, err)
 	}
+	pkg.XAddDuration.WithLabelValues(key, "ok").Observe(time.Since(start).Seconds())
 	return nil
 }
 
@@ -71,3 +98,57 @@ func UpdateLeaderboard(client *redis.Client, key string, member string, incremen
 	}
 	return nil
 }
+
+// Applies an increment only if its marker key has not been set yet. Both
+// happen inside one script so a relay crash can never leave one without the
+// other.
+var incrementOnce = redis.NewScript(`
+if redis.call("EXISTS", KEYS[2]) == 1 then
+	return 0
+end
+redis.call("ZINCRBY", KEYS[1], ARGV[1], ARGV[2])
+redis.call("SET", KEYS[2], 1, "EX", ARGV[3])
+return 1
+`)
+
+// UpdateLeaderboardOnce behaves like UpdateLeaderboard but is idempotent for
+// a given marker key, which is kept for a week.
+func UpdateLeaderboardOnce(ctx context.Context, client *redis.Client, key string,
+	member string, increment float64, marker string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	ttl := int((7 * 24 * time.Hour).Seconds())
+	_, err := incrementOnce.Run(ctx, client, []string{key, marker},
+		increment, member, ttl).Result()
+	if err != nil {
+		return fmt.Errorf("Failed to update leaderboard: %v", err)
+	}
+	return nil
+}
+
+// MarkDelivery records a webhook delivery key for the given TTL. It returns
+// false if the key was already present, i.e. the delivery is a duplicate.
+func MarkDelivery(client *redis.Client, key string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ok, err := client.SetNX(ctx, key, time.Now().Unix(), ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("Failed to mark delivery: %v", err)
+	}
+	return ok, nil
+}
+
+// ForgetDelivery removes a delivery key so that a redelivery of a failed
+// webhook is processed again.
+func ForgetDelivery(client *redis.Client, key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := client.Del(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("Failed to forget delivery: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/IAmRiteshKoushik/alfred/cmd"
+	db "github.com/IAmRiteshKoushik/alfred/db/gen"
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/IAmRiteshKoushik/alfred/worker"
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v74/github"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// archiveDelivery stores the raw delivery exactly as GitHub sent it. A row
+// inserted as queued is picked up from there by the delivery queue.
+func archiveDelivery(c *gin.Context, eventType string, orderingKey string,
+	payload []byte, outcome string) (uuid.UUID, error) {
+
+	// The trace context is stored with the headers so that processing the
+	// delivery later continues the trace started when it was received.
+	header := c.Request.Header.Clone()
+	otel.GetTextMapPropagator().Inject(c.Request.Context(), propagation.HeaderCarrier(header))
+	headers, err := json.Marshal(header)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("failed to marshal headers: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	q := db.New()
+	id, err := q.AddWebhookDeliveryQuery(ctx, cmd.DBPool, db.AddWebhookDeliveryQueryParams{
+		DeliveryID:  c.GetHeader(github.DeliveryIDHeader),
+		RequestID:   pkg.GrabRequestId(c),
+		EventType:   eventType,
+		OrderingKey: orderingKey,
+		Headers:     headers,
+		Payload:     payload,
+		Outcome:     outcome,
+	})
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("failed to insert delivery: %w", err)
+	}
+	return id, nil
+}
+
+// rejectDelivery records why an archived delivery failed validation
+func rejectDelivery(c *gin.Context, archiveId uuid.UUID, reason error) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	q := db.New()
+	err := q.UpdateWebhookDeliveryOutcomeQuery(ctx, cmd.DBPool, db.UpdateWebhookDeliveryOutcomeQueryParams{
+		Outcome:    worker.Rejected,
+		StatusCode: pgtype.Int4{Int32: http.StatusBadRequest, Valid: true},
+		LastError:  pgtype.Text{String: reason.Error(), Valid: true},
+		ID:         archiveId,
+	})
+	if err != nil {
+		pkg.Log.Error(c, "Failed to record outcome for delivery "+archiveId.String(), err)
+	}
+}
+
+// ProcessDelivery runs an archived delivery through the same dispatch path as
+// a live webhook. Handlers respond through a gin context, so a detached one
+// is built from the stored headers and the status it ends up with is
+// returned as the result of processing.
+func ProcessDelivery(delivery db.WebhookDelivery) (int, error) {
+	var headers http.Header
+	if err := json.Unmarshal(delivery.Headers, &headers); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal headers: %w", err)
+	}
+
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(),
+		propagation.HeaderCarrier(headers))
+	ctx, span := pkg.Tracer.Start(ctx, "webhook dispatch "+delivery.EventType,
+		trace.WithAttributes(
+			attribute.String("github.event", delivery.EventType),
+			attribute.String("github.delivery_id", delivery.DeliveryID),
+			attribute.String("alfred.request_id", delivery.RequestID),
+			attribute.String("alfred.ordering_key", delivery.OrderingKey),
+			attribute.Int("alfred.attempt", int(delivery.Attempts)+1),
+		))
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/api/webhook",
+		bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header = headers
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	c.Set("request_id", delivery.RequestID)
+
+	start := time.Now()
+	dispatchEvent(c, delivery.EventType, delivery.Payload)
+	status := c.Writer.Status()
+	pkg.HandlerDuration.WithLabelValues(delivery.EventType, strconv.Itoa(status)).
+		Observe(time.Since(start).Seconds())
+
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, fmt.Sprintf("handler responded with %d", status))
+	}
+	return status, nil
+}
+
+// ReplayDelivery processes an archived delivery immediately, outside of the
+// queue, and records the new outcome. Signature checks and duplicate
+// detection are skipped as the payload comes from our own archive.
+func ReplayDelivery(delivery db.WebhookDelivery) (int, error) {
+	status, err := ProcessDelivery(delivery)
+	if err != nil {
+		return 0, err
+	}
+
+	params := db.UpdateWebhookDeliveryOutcomeQueryParams{
+		Outcome:    worker.Processed,
+		StatusCode: pgtype.Int4{Int32: int32(status), Valid: true},
+		Attempts:   delivery.Attempts + 1,
+		ID:         delivery.ID,
+	}
+	switch {
+	case status >= http.StatusInternalServerError:
+		params.Outcome = worker.Failed
+		params.LastError = pgtype.Text{
+			String: fmt.Sprintf("replay responded with %d", status),
+			Valid:  true,
+		}
+	case status >= http.StatusBadRequest:
+		params.Outcome = worker.Rejected
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	q := db.New()
+	if err := q.UpdateWebhookDeliveryOutcomeQuery(ctx, cmd.DBPool, params); err != nil {
+		return status, fmt.Errorf("failed to record outcome: %w", err)
+	}
+	return status, nil
+}
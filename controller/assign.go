@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IAmRiteshKoushik/alfred/cmd"
+	db "github.com/IAmRiteshKoushik/alfred/db/gen"
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/IAmRiteshKoushik/alfred/worker"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Actions recorded in the claim audit
+const (
+	AuditAssign   = "assign"
+	AuditUnassign = "unassign"
+)
+
+// auditClaim records a maintainer handing out or taking away a claim
+func auditClaim(ctx context.Context, tx pgx.Tx, action IssueAction, auditAction string) error {
+	err := db.New().AddClaimAuditQuery(ctx, tx, db.AddClaimAuditQueryParams{
+		IssueUrl:    action.Url,
+		Ghusername:  action.ParticipantUsername,
+		Action:      auditAction,
+		PerformedBy: action.ActedBy,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add claim audit: %w", err)
+	}
+	return nil
+}
+
+// releaseClaim takes a claim away on behalf of a maintainer. The claim is
+// removed straight away instead of when GitHub reports the unassignment, so
+// that the issue goes to whoever the maintainer picks rather than to the
+// waitlist. It reports whether the participant held an active claim.
+func releaseClaim(ctx context.Context, tx pgx.Tx, action IssueAction, deliveryId string) (bool, error) {
+	q := db.New()
+	_, err := q.IssueUnassignQuery(ctx, tx, db.IssueUnassignQueryParams{
+		Ghusername: action.ParticipantUsername,
+		IssueUrl:   action.Url,
+	})
+	released := err == nil
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return false, fmt.Errorf("failed to remove claim: %w", err)
+	}
+	pending, err := q.DeletePendingClaimQuery(ctx, tx, db.DeletePendingClaimQueryParams{
+		IssueUrl:   action.Url,
+		Ghusername: action.ParticipantUsername,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to drop pending claim: %w", err)
+	}
+	released = released || pending > 0
+	if err = auditClaim(ctx, tx, action, AuditUnassign); err != nil {
+		return false, err
+	}
+	if err = queueStream(ctx, tx, pkg.IssueClaim, pkg.IssueActionEvent, deliveryId, action); err != nil {
+		return false, fmt.Errorf("failed to queue unclaim event: %w", err)
+	}
+	if released {
+		update := newLiveUpdate(LiveIssueUnclaimed, action.ParticipantUsername, action.Url)
+		if err = queueLiveUpdate(ctx, tx, deliveryId, update); err != nil {
+			return false, fmt.Errorf("failed to queue live update: %w", err)
+		}
+	}
+	return released, nil
+}
+
+// claimByMaintainer handles /assign @participant and /unassign @participant.
+// Maintainers are not held to the claim limits or the waitlist. Assigning an
+// issue someone else holds takes it away from them first, unassigning hands
+// the issue to the next participant in the waitlist.
+func claimByMaintainer(ctx context.Context, action IssueAction, source commentSource,
+	deliveryId string) (string, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	tx, err := cmd.BeginTx(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = markLineHandled(ctx, tx, source); err != nil {
+		return "", err
+	}
+
+	message := "Assigned " + action.ParticipantUsername
+	if action.Claimed {
+		err = assignByMaintainer(ctx, tx, action, deliveryId)
+	} else {
+		message = "Unassigned " + action.ParticipantUsername
+		err = unassignByMaintainer(ctx, tx, action, deliveryId)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	worker.NotifyOutbox()
+	return message, nil
+}
+
+func assignByMaintainer(ctx context.Context, tx pgx.Tx, action IssueAction, deliveryId string) error {
+	q := db.New()
+	exists, err := q.ParticipantExistsQuery(ctx, tx, pgtype.Text{
+		String: action.ParticipantUsername,
+		Valid:  true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to check participant existence: %w", err)
+	}
+	if !exists {
+		return &CommandError{
+			Reason:  ReasonUnknownParticipant,
+			Message: action.ParticipantUsername + " is not registered for the season",
+		}
+	}
+
+	claimant, err := q.GetIssueClaimantQuery(ctx, tx, action.Url)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to fetch claimant: %w", err)
+	}
+	if claimant != "" && claimant != action.ParticipantUsername {
+		previous := marshalUnassign(claimant, action.Url)
+		previous.ActedBy = action.ActedBy
+		if _, err = releaseClaim(ctx, tx, previous, deliveryId); err != nil {
+			return err
+		}
+	}
+
+	_, err = q.LeaveWaitlistQuery(ctx, tx, db.LeaveWaitlistQueryParams{
+		IssueUrl:   action.Url,
+		Ghusername: action.ParticipantUsername,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to leave waitlist: %w", err)
+	}
+	// The maintainer's pick takes the issue over from any assignment
+	// still on its way to GitHub
+	if err = q.ClearPendingClaimQuery(ctx, tx, action.Url); err != nil {
+		return fmt.Errorf("failed to clear pending claim: %w", err)
+	}
+	if err = holdPendingClaim(ctx, tx, action.Url, action.ParticipantUsername); err != nil {
+		return err
+	}
+	if err = auditClaim(ctx, tx, action, AuditAssign); err != nil {
+		return err
+	}
+	if err = queueStream(ctx, tx, pkg.IssueClaim, pkg.IssueActionEvent, deliveryId, action); err != nil {
+		return fmt.Errorf("failed to queue claim event: %w", err)
+	}
+	return nil
+}
+
+func unassignByMaintainer(ctx context.Context, tx pgx.Tx, action IssueAction, deliveryId string) error {
+	_, err := db.New().LeaveWaitlistQuery(ctx, tx, db.LeaveWaitlistQueryParams{
+		IssueUrl:   action.Url,
+		Ghusername: action.ParticipantUsername,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to leave waitlist: %w", err)
+	}
+	released, err := releaseClaim(ctx, tx, action, deliveryId)
+	if err != nil {
+		return err
+	}
+	if released {
+		return promoteFromWaitlist(ctx, tx, action.Url, deliveryId)
+	}
+	return nil
+}
@@ -0,0 +1,585 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/IAmRiteshKoushik/alfred/cmd"
+	db "github.com/IAmRiteshKoushik/alfred/db/gen"
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/IAmRiteshKoushik/alfred/worker"
+	"github.com/jackc/pgx/v5"
+)
+
+// issueNotClaimable checks that an issue is open for claims this season. It
+// returns the reason and message for turning the claim down, or an empty
+// reason when the issue can be claimed.
+func issueNotClaimable(issue db.GetClaimableIssueQueryRow, err error) (string, string, error) {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return ReasonIssueNotAccepted, "This issue has not been accepted into the season", nil
+	case err != nil:
+		return "", "", fmt.Errorf("failed to fetch issue: %w", err)
+	case issue.Resolved:
+		return ReasonIssueResolved, "This issue has already been resolved", nil
+	case !issue.OnDisplay:
+		return ReasonRepoNotOnDisplay, "This repository is not taking part in the season", nil
+	}
+	return "", "", nil
+}
+
+// claimLimitReached checks the active claims of a participant, counted by
+// difficulty, against the configured limits before they take on an issue of
+// the given difficulty. It returns the reason and message for turning the
+// claim down, or an empty reason when the claim is allowed.
+func claimLimitReached(claims []db.CountActiveClaimsQueryRow, difficulty string) (string, string) {
+	total := 0
+	held := 0
+	for _, row := range claims {
+		total += int(row.Claims)
+		if row.Difficulty == difficulty {
+			held = int(row.Claims)
+		}
+	}
+	if limit := cmd.AppConfig.MaxActiveClaims; limit > 0 && total >= limit {
+		return ReasonClaimLimit, fmt.Sprintf(
+			"You already hold %d active claims, the limit is %d", total, limit)
+	}
+	if limit := cmd.AppConfig.MaxActiveClaimsByDifficulty[difficulty]; limit > 0 && held >= limit {
+		return ReasonDifficultyClaimLimit, fmt.Sprintf(
+			"You already hold %d active claims on %s issues, the limit is %d",
+			held, strings.ToLower(difficulty), limit)
+	}
+	return "", ""
+}
+
+// issueDifficulty returns the difficulty label of an issue, issues that are
+// not tracked count as EASY
+func issueDifficulty(ctx context.Context, tx db.DBTX, url string) (string, error) {
+	difficulty, err := db.New().GetIssueDifficultyQuery(ctx, tx, url)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "EASY", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch issue difficulty: %w", err)
+	}
+	return strings.ToUpper(difficulty), nil
+}
+
+// Where a participant stands on an issue in a claim-status reply
+const (
+	StatusClaimed    = "claimed"
+	StatusWaitlisted = "waitlisted"
+	StatusNotQueued  = "not-queued"
+)
+
+// ClaimStatus tells a participant where they stand on an issue, in reply to
+// an /assign that was queued or to /queue. Nothing changes on GitHub, so it
+// is kept apart from the issue-action events DevPool assigns and unassigns on.
+type ClaimStatus struct {
+	ParticipantUsername string `json:"github_username"`
+	Url                 string `json:"url"`
+	Status              string `json:"status"`
+	Position            int    `json:"position,omitempty"`
+	Message             string `json:"message"`
+}
+
+func newClaimStatus(action IssueAction, status string, position int, message string) ClaimStatus {
+	return ClaimStatus{
+		ParticipantUsername: action.ParticipantUsername,
+		Url:                 action.Url,
+		Status:              status,
+		Position:            position,
+		Message:             message,
+	}
+}
+
+// errIssueHeld is returned by holdPendingClaim when another participant's
+// assignment got to the issue first
+var errIssueHeld = errors.New("issue is held by another pending claim")
+
+// holdPendingClaim marks the issue as taken by the participant from the
+// moment the assignment is passed on to DevPool until GitHub reports it back.
+// Pending claims count towards the claim limits, so a burst of /assign
+// comments cannot get past them before any of the assignments are recorded.
+func holdPendingClaim(ctx context.Context, tx db.DBTX, url string, username string) error {
+	held, err := db.New().AddPendingClaimQuery(ctx, tx, db.AddPendingClaimQueryParams{
+		IssueUrl:   url,
+		Ghusername: username,
+		Timeout:    cmd.AppConfig.PendingClaimTimeout.Seconds(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to hold pending claim: %w", err)
+	}
+	if held == 0 {
+		return errIssueHeld
+	}
+	return nil
+}
+
+// claimIssue handles /assign from a participant. The claim itself is only
+// recorded once DevPool assigns the participant on GitHub, here it is passed
+// on to DevPool, queued behind the current claimant or turned down.
+func claimIssue(ctx context.Context, action IssueAction, source commentSource,
+	deliveryId string) (string, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	tx, err := cmd.BeginTx(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = markLineHandled(ctx, tx, source); err != nil {
+		return "", err
+	}
+
+	queued, err := decideClaim(ctx, txClaims{tx: tx, deliveryId: deliveryId}, action)
+	if err != nil {
+		return "", err
+	}
+	result := "Claim passed on to DevPool"
+	if queued != nil {
+		err = queueStream(ctx, tx, pkg.IssueClaim, pkg.ClaimStatusEvent, deliveryId, *queued)
+		result = queued.Message
+	} else {
+		err = queueStream(ctx, tx, pkg.IssueClaim, pkg.IssueActionEvent, deliveryId, action)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to queue claim event: %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	worker.NotifyOutbox()
+	return result, nil
+}
+
+// claimStore is what deciding on an /assign reads and writes. txClaims
+// keeps it in the transaction of the command.
+type claimStore interface {
+	claimableIssue(ctx context.Context, url string) (db.GetClaimableIssueQueryRow, error)
+	lockClaims(ctx context.Context, username string) error
+	activeClaims(ctx context.Context, username string) ([]db.CountActiveClaimsQueryRow, error)
+	claimant(ctx context.Context, url string) (string, error)
+	holdPending(ctx context.Context, url string, username string) error
+	joinWaitlist(ctx context.Context, url string, username string) (int, error)
+	waiting(ctx context.Context, url string) (int, error)
+	promote(ctx context.Context, url string) error
+}
+
+type txClaims struct {
+	tx         db.DBTX
+	deliveryId string
+}
+
+// claimableIssue returns pgx.ErrNoRows as is, for issueNotClaimable
+func (c txClaims) claimableIssue(ctx context.Context, url string) (db.GetClaimableIssueQueryRow, error) {
+	return db.New().GetClaimableIssueQuery(ctx, c.tx, url)
+}
+
+// lockClaims makes /assign comments of one participant wait for each other,
+// so that their claims are counted and held one at a time
+func (c txClaims) lockClaims(ctx context.Context, username string) error {
+	if err := db.New().LockParticipantClaimsQuery(ctx, c.tx, username); err != nil {
+		return fmt.Errorf("failed to lock participant claims: %w", err)
+	}
+	return nil
+}
+
+func (c txClaims) activeClaims(ctx context.Context, username string) ([]db.CountActiveClaimsQueryRow, error) {
+	claims, err := db.New().CountActiveClaimsQuery(ctx, c.tx, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count active claims: %w", err)
+	}
+	return claims, nil
+}
+
+// claimant returns who holds the issue, empty when nobody does
+func (c txClaims) claimant(ctx context.Context, url string) (string, error) {
+	claimant, err := db.New().GetIssueClaimantQuery(ctx, c.tx, url)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch claimant: %w", err)
+	}
+	return claimant, nil
+}
+
+func (c txClaims) holdPending(ctx context.Context, url string, username string) error {
+	return holdPendingClaim(ctx, c.tx, url, username)
+}
+
+// joinWaitlist returns the position of the participant in the waitlist
+func (c txClaims) joinWaitlist(ctx context.Context, url string, username string) (int, error) {
+	err := db.New().AddToWaitlistQuery(ctx, c.tx, db.AddToWaitlistQueryParams{
+		IssueUrl:   url,
+		Ghusername: username,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to add to waitlist: %w", err)
+	}
+	return waitlistPosition(ctx, c.tx, marshalAssign(username, url))
+}
+
+// waiting returns how many participants are in the waitlist of the issue
+func (c txClaims) waiting(ctx context.Context, url string) (int, error) {
+	count, err := db.New().CountWaitlistQuery(ctx, c.tx, url)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count waitlist: %w", err)
+	}
+	return int(count), nil
+}
+
+func (c txClaims) promote(ctx context.Context, url string) error {
+	return promoteFromWaitlist(ctx, c.tx, url, c.deliveryId)
+}
+
+// decideClaim works out what becomes of an /assign. Claims on issues that are
+// not part of the season or by participants at their claim limit are turned
+// down with a *CommandError, otherwise they join the waitlist when someone
+// else holds the issue, be it through a recorded claim or one still on its
+// way to GitHub, or when others are already waiting for it. The status to
+// reply with is returned for a queued or promoted claim, nil when the claim
+// is passed on to DevPool.
+func decideClaim(ctx context.Context, claims claimStore, action IssueAction) (*ClaimStatus, error) {
+	issue, err := claims.claimableIssue(ctx, action.Url)
+	reason, message, err := issueNotClaimable(issue, err)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		return nil, &CommandError{Reason: reason, Message: message}
+	}
+	difficulty := strings.ToUpper(issue.Difficulty)
+
+	if err = claims.lockClaims(ctx, action.ParticipantUsername); err != nil {
+		return nil, err
+	}
+	claimant, err := claims.claimant(ctx, action.Url)
+	if err != nil {
+		return nil, err
+	}
+	// A participant asking again for an issue they hold already counts it
+	if claimant != action.ParticipantUsername {
+		held, err := claims.activeClaims(ctx, action.ParticipantUsername)
+		if err != nil {
+			return nil, err
+		}
+		if reason, message := claimLimitReached(held, difficulty); reason != "" {
+			return nil, &CommandError{Reason: reason, Message: message}
+		}
+	}
+
+	if claimant == "" {
+		waiting, err := claims.waiting(ctx, action.Url)
+		if err != nil {
+			return nil, err
+		}
+		if waiting > 0 {
+			return joinLine(ctx, claims, action)
+		}
+	}
+	if claimant == "" || claimant == action.ParticipantUsername {
+		err = claims.holdPending(ctx, action.Url, action.ParticipantUsername)
+		if !errors.Is(err, errIssueHeld) {
+			return nil, err
+		}
+		// An /assign from someone else got there while this one was decided
+		if claimant, err = claims.claimant(ctx, action.Url); err != nil {
+			return nil, err
+		}
+	}
+	position, err := claims.joinWaitlist(ctx, action.Url, action.ParticipantUsername)
+	if err != nil {
+		return nil, err
+	}
+	status := newClaimStatus(action, StatusWaitlisted, position,
+		fmt.Sprintf("This issue is claimed by %s, you are #%d in the queue", claimant, position))
+	return &status, nil
+}
+
+// joinLine queues a claim on an issue nobody holds behind the participants
+// already waiting for it. The first of them within their claim limits is
+// promoted right away, which may be the participant asking.
+func joinLine(ctx context.Context, claims claimStore, action IssueAction) (*ClaimStatus, error) {
+	position, err := claims.joinWaitlist(ctx, action.Url, action.ParticipantUsername)
+	if err != nil {
+		return nil, err
+	}
+	if err = claims.promote(ctx, action.Url); err != nil {
+		return nil, err
+	}
+	claimant, err := claims.claimant(ctx, action.Url)
+	if err != nil {
+		return nil, err
+	}
+	if claimant == action.ParticipantUsername {
+		status := newClaimStatus(action, StatusClaimed, 0,
+			"You were first in line for this issue, the claim is passed on to DevPool")
+		return &status, nil
+	}
+	if claimant != "" {
+		// Promoting moved everyone ahead of the participant up by one
+		position--
+	}
+	status := newClaimStatus(action, StatusWaitlisted, position,
+		fmt.Sprintf("Others are waiting for this issue, you are #%d in the queue", position))
+	return &status, nil
+}
+
+// waitlistPosition returns where the participant stands in the waitlist of
+// the issue, counting from 1, or 0 when they are not waiting for it
+func waitlistPosition(ctx context.Context, tx db.DBTX, action IssueAction) (int, error) {
+	position, err := db.New().GetWaitlistPositionQuery(ctx, tx, db.GetWaitlistPositionQueryParams{
+		IssueUrl:   action.Url,
+		Ghusername: action.ParticipantUsername,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch waitlist position: %w", err)
+	}
+	return int(position), nil
+}
+
+// refuseAssignment handles GitHub reporting an assignment on an issue that
+// another participant holds, as happens when /assign comments cross or a
+// participant is assigned by hand. Instead of a second claim the assignee
+// joins the waitlist and DevPool is asked to unassign them again.
+func refuseAssignment(ctx context.Context, tx pgx.Tx, username string, url string,
+	claimant string, deliveryId string) error {
+
+	q := db.New()
+	_, err := q.DeletePendingClaimQuery(ctx, tx, db.DeletePendingClaimQueryParams{
+		IssueUrl:   url,
+		Ghusername: username,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete pending claim: %w", err)
+	}
+	err = q.AddToWaitlistQuery(ctx, tx, db.AddToWaitlistQueryParams{
+		IssueUrl:   url,
+		Ghusername: username,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add to waitlist: %w", err)
+	}
+	action := marshalUnassign(username, url)
+	position, err := waitlistPosition(ctx, tx, action)
+	if err != nil {
+		return err
+	}
+
+	if err = queueStream(ctx, tx, pkg.IssueClaim, pkg.IssueActionEvent, deliveryId, action); err != nil {
+		return fmt.Errorf("failed to queue unassign: %w", err)
+	}
+	status := newClaimStatus(action, StatusWaitlisted, position,
+		fmt.Sprintf("This issue is claimed by %s, you are #%d in the queue", claimant, position))
+	if err = queueStream(ctx, tx, pkg.IssueClaim, pkg.ClaimStatusEvent, deliveryId, status); err != nil {
+		return fmt.Errorf("failed to queue claim status: %w", err)
+	}
+	return nil
+}
+
+// queueStatus answers /queue with where the participant stands on the issue
+func queueStatus(ctx context.Context, action IssueAction, source commentSource,
+	deliveryId string) (string, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	tx, err := cmd.BeginTx(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = markLineHandled(ctx, tx, source); err != nil {
+		return "", err
+	}
+	claimant, err := db.New().GetIssueClaimantQuery(ctx, tx, action.Url)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", fmt.Errorf("failed to fetch claimant: %w", err)
+	}
+	position, err := waitlistPosition(ctx, tx, action)
+	if err != nil {
+		return "", err
+	}
+
+	var status ClaimStatus
+	switch {
+	case claimant == action.ParticipantUsername:
+		status = newClaimStatus(action, StatusClaimed, 0, "You hold the claim on this issue")
+	case position > 0:
+		status = newClaimStatus(action, StatusWaitlisted, position,
+			fmt.Sprintf("You are #%d in the queue for this issue", position))
+	default:
+		status = newClaimStatus(action, StatusNotQueued, 0, "You are not in the queue for this issue")
+	}
+
+	err = queueStream(ctx, tx, pkg.IssueClaim, pkg.ClaimStatusEvent, deliveryId, status)
+	if err != nil {
+		return "", fmt.Errorf("failed to queue claim status: %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	worker.NotifyOutbox()
+	return status.Message, nil
+}
+
+// promoteFromWaitlist hands a released issue to the first participant in its
+// waitlist who is still within their claim limits. DevPool assigns them on
+// GitHub, which records the claim like any other assignment. Nothing happens
+// while someone still holds the issue.
+func promoteFromWaitlist(ctx context.Context, tx db.DBTX, url string, deliveryId string) error {
+	q := db.New()
+	_, err := q.GetIssueClaimantQuery(ctx, tx, url)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to fetch claimant: %w", err)
+	}
+	waiting, err := q.GetWaitlistQuery(ctx, tx, url)
+	if err != nil {
+		return fmt.Errorf("failed to fetch waitlist: %w", err)
+	}
+	if len(waiting) == 0 {
+		return nil
+	}
+	difficulty, err := issueDifficulty(ctx, tx, url)
+	if err != nil {
+		return err
+	}
+	for _, entry := range waiting {
+		claims, err := q.CountActiveClaimsQuery(ctx, tx, entry.Ghusername)
+		if err != nil {
+			return fmt.Errorf("failed to count active claims: %w", err)
+		}
+		if reason, _ := claimLimitReached(claims, difficulty); reason != "" {
+			continue
+		}
+		err = holdPendingClaim(ctx, tx, url, entry.Ghusername)
+		if errors.Is(err, errIssueHeld) {
+			// Someone else's assignment is on its way, the line waits
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = q.DeleteWaitlistEntryQuery(ctx, tx, entry.ID); err != nil {
+			return fmt.Errorf("failed to remove waitlist entry: %w", err)
+		}
+		action := marshalAssign(entry.Ghusername, url)
+		action.Promoted = true
+		if err = queueStream(ctx, tx, pkg.IssueClaim, pkg.IssueActionEvent, deliveryId, action); err != nil {
+			return fmt.Errorf("failed to queue promotion: %w", err)
+		}
+		return nil
+	}
+	return nil
+}
+
+// unclaimIssue handles /unassign from a participant. It is passed on to
+// DevPool and also takes the participant out of the waitlist of the issue.
+func unclaimIssue(ctx context.Context, action IssueAction, source commentSource,
+	deliveryId string) (string, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	tx, err := cmd.BeginTx(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = markLineHandled(ctx, tx, source); err != nil {
+		return "", err
+	}
+
+	q := db.New()
+	left, err := q.LeaveWaitlistQuery(ctx, tx, db.LeaveWaitlistQueryParams{
+		IssueUrl:   action.Url,
+		Ghusername: action.ParticipantUsername,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to leave waitlist: %w", err)
+	}
+	_, err = q.DeletePendingClaimQuery(ctx, tx, db.DeletePendingClaimQueryParams{
+		IssueUrl:   action.Url,
+		Ghusername: action.ParticipantUsername,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to drop pending claim: %w", err)
+	}
+	err = queueStream(ctx, tx, pkg.IssueClaim, pkg.IssueActionEvent, deliveryId, action)
+	if err != nil {
+		return "", fmt.Errorf("failed to queue issue action: %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	worker.NotifyOutbox()
+
+	if left > 0 {
+		return "Left the queue and passed the unassign on to DevPool", nil
+	}
+	return "Unassign passed on to DevPool", nil
+}
+
+// claimDeadline formats when a claim elapses for the event payloads
+func claimDeadline(claim db.IssueClaim) string {
+	return claim.ElapsedOn.Time.UTC().Format(time.RFC3339)
+}
+
+// ReleaseExpiredClaim queues the events for a claim the sweeper has removed.
+// DevPool unassigns the participant on GitHub and Pulse shows the issue as
+// open again, unless someone in the waitlist takes it over.
+func ReleaseExpiredClaim(ctx context.Context, tx pgx.Tx, claim db.IssueClaim) error {
+	action := marshalUnassign(claim.Ghusername, claim.IssueUrl)
+	action.Expired = true
+	action.Deadline = claimDeadline(claim)
+	if err := queueStream(ctx, tx, pkg.IssueClaim, pkg.IssueActionEvent, "", action); err != nil {
+		return fmt.Errorf("failed to queue unclaim event: %w", err)
+	}
+	update := newLiveUpdate(LiveIssueUnclaimed, claim.Ghusername, claim.IssueUrl)
+	if err := queueLiveUpdate(ctx, tx, "", update); err != nil {
+		return fmt.Errorf("failed to queue live update: %w", err)
+	}
+	return promoteFromWaitlist(ctx, tx, claim.IssueUrl, "")
+}
+
+// ReleaseExpiredPendingClaim passes an issue on to its waitlist once the
+// sweeper has removed an assignment GitHub never reported back
+func ReleaseExpiredPendingClaim(ctx context.Context, tx pgx.Tx, claim db.PendingClaim) error {
+	return promoteFromWaitlist(ctx, tx, claim.IssueUrl, "")
+}
+
+// ClaimReminder warns a participant that their claim is about to elapse.
+// Nothing changes on GitHub, DevPool posts it on the issue.
+type ClaimReminder struct {
+	ParticipantUsername string `json:"github_username"`
+	Url                 string `json:"url"`
+	Deadline            string `json:"deadline"`
+}
+
+// RemindClaim queues a reminder for DevPool to post on the issue before the
+// claim elapses
+func RemindClaim(ctx context.Context, tx pgx.Tx, claim db.IssueClaim) error {
+	reminder := ClaimReminder{
+		ParticipantUsername: claim.Ghusername,
+		Url:                 claim.IssueUrl,
+		Deadline:            claimDeadline(claim),
+	}
+	if err := queueStream(ctx, tx, pkg.IssueClaim, pkg.ClaimReminderEvent, "", reminder); err != nil {
+		return fmt.Errorf("failed to queue reminder: %w", err)
+	}
+	return nil
+}
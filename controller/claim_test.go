@@ -0,0 +1,231 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/IAmRiteshKoushik/alfred/cmd"
+	db "github.com/IAmRiteshKoushik/alfred/db/gen"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestClaimLimitReached(t *testing.T) {
+	cmd.AppConfig = &cmd.EnvConfig{
+		MaxActiveClaims:             3,
+		MaxActiveClaimsByDifficulty: map[string]int{"HARD": 1},
+	}
+	defer func() { cmd.AppConfig = nil }()
+
+	tests := []struct {
+		name       string
+		claims     []db.CountActiveClaimsQueryRow
+		difficulty string
+		want       string
+	}{
+		{"no claims", nil, "HARD", ""},
+		{"below total", []db.CountActiveClaimsQueryRow{{Difficulty: "EASY", Claims: 2}}, "EASY", ""},
+		{"at total", []db.CountActiveClaimsQueryRow{
+			{Difficulty: "EASY", Claims: 2},
+			{Difficulty: "MEDIUM", Claims: 1},
+		}, "EASY", ReasonClaimLimit},
+		{"at difficulty", []db.CountActiveClaimsQueryRow{{Difficulty: "HARD", Claims: 1}}, "HARD", ReasonDifficultyClaimLimit},
+		{"other difficulty", []db.CountActiveClaimsQueryRow{{Difficulty: "HARD", Claims: 1}}, "MEDIUM", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, message := claimLimitReached(tt.claims, tt.difficulty)
+			if reason != tt.want {
+				t.Errorf("got reason %q, want %q", reason, tt.want)
+			}
+			if (reason == "") != (message == "") {
+				t.Errorf("reason %q does not match message %q", reason, message)
+			}
+		})
+	}
+}
+
+func TestIssueNotClaimable(t *testing.T) {
+	tests := []struct {
+		name  string
+		issue db.GetClaimableIssueQueryRow
+		err   error
+		want  string
+	}{
+		{"open", db.GetClaimableIssueQueryRow{Difficulty: "EASY", OnDisplay: true}, nil, ""},
+		{"not accepted", db.GetClaimableIssueQueryRow{}, pgx.ErrNoRows, ReasonIssueNotAccepted},
+		{"resolved", db.GetClaimableIssueQueryRow{Resolved: true, OnDisplay: true}, nil, ReasonIssueResolved},
+		{"hidden repository", db.GetClaimableIssueQueryRow{}, nil, ReasonRepoNotOnDisplay},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, _, err := issueNotClaimable(tt.issue, tt.err)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reason != tt.want {
+				t.Errorf("got reason %q, want %q", reason, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := issueNotClaimable(db.GetClaimableIssueQueryRow{}, errors.New("down")); err == nil {
+		t.Error("expected database errors to be returned")
+	}
+}
+
+// fakeClaims keeps claims in memory for decideClaim. Every issue is accepted,
+// open and EASY. Claims and pending claims map an issue to the participant
+// holding it, the waitlist to the participants in line.
+type fakeClaims struct {
+	claims   map[string]string
+	pending  map[string]string
+	waitlist map[string][]string
+}
+
+func newFakeClaims() *fakeClaims {
+	return &fakeClaims{
+		claims:   map[string]string{},
+		pending:  map[string]string{},
+		waitlist: map[string][]string{},
+	}
+}
+
+func (f *fakeClaims) claimableIssue(context.Context, string) (db.GetClaimableIssueQueryRow, error) {
+	return db.GetClaimableIssueQueryRow{Difficulty: "EASY", OnDisplay: true}, nil
+}
+
+func (f *fakeClaims) lockClaims(context.Context, string) error {
+	return nil
+}
+
+func (f *fakeClaims) activeClaims(_ context.Context, username string) ([]db.CountActiveClaimsQueryRow, error) {
+	issues := map[string]bool{}
+	for _, held := range []map[string]string{f.claims, f.pending} {
+		for url, holder := range held {
+			if holder == username {
+				issues[url] = true
+			}
+		}
+	}
+	if len(issues) == 0 {
+		return nil, nil
+	}
+	return []db.CountActiveClaimsQueryRow{{Difficulty: "EASY", Claims: int64(len(issues))}}, nil
+}
+
+func (f *fakeClaims) claimant(_ context.Context, url string) (string, error) {
+	if holder, ok := f.claims[url]; ok {
+		return holder, nil
+	}
+	return f.pending[url], nil
+}
+
+func (f *fakeClaims) holdPending(_ context.Context, url string, username string) error {
+	if holder, ok := f.pending[url]; ok && holder != username {
+		return errIssueHeld
+	}
+	f.pending[url] = username
+	return nil
+}
+
+func (f *fakeClaims) joinWaitlist(_ context.Context, url string, username string) (int, error) {
+	if !slices.Contains(f.waitlist[url], username) {
+		f.waitlist[url] = append(f.waitlist[url], username)
+	}
+	return slices.Index(f.waitlist[url], username) + 1, nil
+}
+
+func (f *fakeClaims) waiting(_ context.Context, url string) (int, error) {
+	return len(f.waitlist[url]), nil
+}
+
+// promote hands the issue to the first in line, claim limits are left out
+func (f *fakeClaims) promote(ctx context.Context, url string) error {
+	if holder, _ := f.claimant(ctx, url); holder != "" || len(f.waitlist[url]) == 0 {
+		return nil
+	}
+	f.pending[url] = f.waitlist[url][0]
+	f.waitlist[url] = f.waitlist[url][1:]
+	return nil
+}
+
+func TestDecideClaimBurst(t *testing.T) {
+	cmd.AppConfig = &cmd.EnvConfig{MaxActiveClaims: 3}
+	defer func() { cmd.AppConfig = nil }()
+
+	// None of the assignments have come back from GitHub while the
+	// comments are handled
+	claims := newFakeClaims()
+	accepted := 0
+	for i := range 20 {
+		url := fmt.Sprintf("https://github.com/o/r/issues/%d", i+1)
+		_, err := decideClaim(context.Background(), claims, marshalAssign("octocat", url))
+		var commandErr *CommandError
+		switch {
+		case err == nil:
+			accepted++
+		case errors.As(err, &commandErr) && commandErr.Reason == ReasonClaimLimit:
+		default:
+			t.Fatalf("claim %d: %v", i+1, err)
+		}
+	}
+	if accepted != 3 {
+		t.Errorf("accepted %d claims in a burst, want 3", accepted)
+	}
+	if len(claims.pending) != 3 {
+		t.Errorf("holding %d pending claims, want 3", len(claims.pending))
+	}
+}
+
+func TestDecideClaimBeforeAssigned(t *testing.T) {
+	cmd.AppConfig = &cmd.EnvConfig{MaxActiveClaims: 3}
+	defer func() { cmd.AppConfig = nil }()
+
+	// The second /assign comes in before GitHub reports the first assignment
+	claims := newFakeClaims()
+	url := "https://github.com/o/r/issues/1"
+	queued, err := decideClaim(context.Background(), claims, marshalAssign("octocat", url))
+	if err != nil || queued != nil {
+		t.Fatalf("first claim: got %v, %v, want it passed on", queued, err)
+	}
+	queued, err = decideClaim(context.Background(), claims, marshalAssign("hubot", url))
+	if err != nil {
+		t.Fatalf("second claim: %v", err)
+	}
+	if queued == nil || queued.Status != StatusWaitlisted || queued.Position != 1 {
+		t.Errorf("second claim: got %+v, want waitlisted at #1", queued)
+	}
+	if claims.pending[url] != "octocat" {
+		t.Errorf("issue held by %q, want octocat", claims.pending[url])
+	}
+
+	// Asking again for a held issue is passed on, not queued
+	queued, err = decideClaim(context.Background(), claims, marshalAssign("octocat", url))
+	if err != nil || queued != nil {
+		t.Errorf("repeated claim: got %v, %v, want it passed on", queued, err)
+	}
+}
+
+func TestDecideClaimBehindWaitlist(t *testing.T) {
+	cmd.AppConfig = &cmd.EnvConfig{MaxActiveClaims: 3}
+	defer func() { cmd.AppConfig = nil }()
+
+	// The pending claim lapsed before the sweeper promoted the waitlist
+	claims := newFakeClaims()
+	url := "https://github.com/o/r/issues/1"
+	claims.waitlist[url] = []string{"hubot"}
+
+	queued, err := decideClaim(context.Background(), claims, marshalAssign("octocat", url))
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if queued == nil || queued.Status != StatusWaitlisted || queued.Position != 1 {
+		t.Errorf("claim: got %+v, want waitlisted at #1", queued)
+	}
+	if claims.pending[url] != "hubot" {
+		t.Errorf("issue held by %q, want hubot", claims.pending[url])
+	}
+}
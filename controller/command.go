@@ -0,0 +1,358 @@
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Reasons a command is turned down. The parse reasons come from the grammar,
+// the others from carrying the command out.
+const (
+	ReasonMissingArgument    = "missing-argument"
+	ReasonUnexpectedArgument = "unexpected-argument"
+	ReasonInvalidNumber      = "invalid-number"
+	ReasonInvalidUsername    = "invalid-username"
+	ReasonUnknownParticipant = "unknown-participant"
+	ReasonNoActiveClaim      = "no-active-claim"
+	ReasonNoPendingExtension = "no-pending-extension"
+	ReasonNothingToUndo      = "nothing-to-undo"
+
+	// Claims turned down by the season rules
+	ReasonIssueNotAccepted     = "issue-not-accepted"
+	ReasonIssueResolved        = "issue-resolved"
+	ReasonRepoNotOnDisplay     = "repository-not-on-display"
+	ReasonClaimLimit           = "claim-limit"
+	ReasonDifficultyClaimLimit = "difficulty-claim-limit"
+)
+
+// CommandError is a command that could not be parsed or carried out because
+// of what was written, as opposed to a failure of Alfred. Line and Column
+// point into the comment body and start at 1.
+type CommandError struct {
+	Command string
+	Line    int
+	Column  int
+	Reason  string
+	Message string
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("%s at line %d, column %d: %s", e.Command, e.Line, e.Column, e.Message)
+}
+
+// CommandRejected is published on command-rejected-stream for every command
+// that was turned down, so that DevPool can reply on the issue
+type CommandRejected struct {
+	Command string `json:"command"`
+	Actor   string `json:"actor"`
+	Url     string `json:"url"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+}
+
+func marshalRejection(err *CommandError, actor string, url string) CommandRejected {
+	return CommandRejected{
+		Command: err.Command,
+		Actor:   actor,
+		Url:     url,
+		Reason:  err.Reason,
+		Message: err.Message,
+		Line:    err.Line,
+		Column:  err.Column,
+	}
+}
+
+// Command is one command found in a comment
+type Command struct {
+	Name   string
+	Type   Comment
+	Line   int
+	Column int
+	Data   AllowedComment
+}
+
+type argKind int
+
+const (
+	argNumber argKind = iota
+	argUser
+)
+
+// The grammar of every command by who may use it. A command is written on a
+// line of its own as the name followed by its arguments, each listed form is
+// one accepted sequence of arguments.
+var participantGrammar = map[string][][]argKind{
+	"/assign":   {{}},
+	"/unassign": {{}},
+	"/queue":    {{}},
+	"/extend":   {{argNumber}},
+}
+
+var maintainerGrammar = map[string][][]argKind{
+	"/bounty":   {{argNumber, argUser}},
+	"/penalty":  {{argNumber, argUser}},
+	"/assign":   {{argUser}},
+	"/unassign": {{argUser}},
+	"/extend":   {{argUser}, {argNumber, argUser}},
+	"/help":     {{argUser}},
+	"/doc":      {{argUser}},
+	"/test":     {{argUser}},
+	"/impact":   {{argUser}},
+	"/bug":      {{argUser}},
+	"/undo":     {{}},
+}
+
+// GitHub usernames are alphanumeric with single hyphens in between
+var githubUsername = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9]|-[A-Za-z0-9]){0,38}$`)
+
+type token struct {
+	text   string
+	column int
+}
+
+// tokenize splits a line on any run of whitespace and remembers where each
+// token starts
+func tokenize(line string) []token {
+	var tokens []token
+	start := -1
+	column := 0
+	for i, r := range line {
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				tokens = append(tokens, token{text: line[start:i], column: column})
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+			column = len([]rune(line[:i])) + 1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{text: line[start:], column: column})
+	}
+	return tokens
+}
+
+type commandLine struct {
+	number int
+	text   string
+}
+
+// commandLines returns the lines of a comment that can hold a command.
+// Quoted replies and fenced code blocks are left out.
+func commandLines(body string) []commandLine {
+	var lines []commandLine
+	fence := ""
+	for i, line := range strings.Split(body, "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			fence = "```"
+		case strings.HasPrefix(trimmed, "~~~"):
+			fence = "~~~"
+		case strings.HasPrefix(trimmed, ">"):
+		case strings.HasPrefix(trimmed, "/"):
+			lines = append(lines, commandLine{number: i + 1, text: line})
+		}
+	}
+	return lines
+}
+
+// parseCommands finds every command in a comment, one per line, in the order
+// they were written. Lines starting with something that is not a command
+// for this commentator are ignored, malformed commands are returned as
+// errors and do not stop the other lines from being parsed.
+func parseCommands(body string, by Commentator, username string,
+	url string) ([]Command, []*CommandError) {
+
+	grammar := participantGrammar
+	switch by {
+	case Maintainer:
+		grammar = maintainerGrammar
+	case UnknownUser:
+		return nil, nil
+	}
+
+	var commands []Command
+	var errs []*CommandError
+	for _, line := range commandLines(body) {
+		tokens := tokenize(line.text)
+		name := strings.ToLower(tokens[0].text)
+		forms, ok := grammar[name]
+		if !ok {
+			continue
+		}
+		command, err := parseCommand(name, forms, tokens, line.number, by, username, url)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		commands = append(commands, command)
+	}
+	return commands, errs
+}
+
+// parseCommand checks the arguments against the forms of the command and
+// builds the payload it carries
+func parseCommand(name string, forms [][]argKind, tokens []token, line int,
+	by Commentator, username string, url string) (Command, *CommandError) {
+
+	args := tokens[1:]
+	fail := func(column int, reason string, message string) (Command, *CommandError) {
+		return Command{}, &CommandError{
+			Command: name,
+			Line:    line,
+			Column:  column,
+			Reason:  reason,
+			Message: message,
+		}
+	}
+
+	var form []argKind
+	found := false
+	for _, f := range forms {
+		if len(f) == len(args) {
+			form, found = f, true
+			break
+		}
+	}
+	if !found {
+		longest := forms[len(forms)-1]
+		if len(args) > len(longest) {
+			return fail(args[len(longest)].column, ReasonUnexpectedArgument,
+				fmt.Sprintf("%s does not take %q, put the command on a line of its own", name,
+					args[len(longest)].text))
+		}
+		last := tokens[len(tokens)-1]
+		return fail(last.column+len([]rune(last.text)), ReasonMissingArgument,
+			fmt.Sprintf("%s expects %s", name, usage(forms)))
+	}
+
+	var numbers []int
+	var users []string
+	for i, kind := range form {
+		arg := args[i]
+		switch kind {
+		case argNumber:
+			number, err := strconv.Atoi(arg.text)
+			if err != nil || number <= 0 {
+				return fail(arg.column, ReasonInvalidNumber,
+					fmt.Sprintf("%q is not a positive whole number", arg.text))
+			}
+			numbers = append(numbers, number)
+		case argUser:
+			// The @ tells a username apart from a number, as in
+			// /extend 5 against /extend @5
+			user, mentioned := strings.CutPrefix(arg.text, "@")
+			if !mentioned {
+				return fail(arg.column, ReasonInvalidUsername,
+					fmt.Sprintf("%q is not a mention, write the username as @%s", arg.text, arg.text))
+			}
+			if !githubUsername.MatchString(user) {
+				return fail(arg.column, ReasonInvalidUsername,
+					fmt.Sprintf("%q is not a GitHub username", arg.text))
+			}
+			users = append(users, user)
+		}
+	}
+
+	command := Command{Name: name, Line: line, Column: tokens[0].column}
+	command.Type, command.Data = buildCommand(name, numbers, users, by, username, url)
+	return command, nil
+}
+
+// buildCommand turns the parsed arguments of a command into its payload
+func buildCommand(name string, numbers []int, users []string, by Commentator,
+	username string, url string) (Comment, AllowedComment) {
+
+	if by == Participant {
+		switch name {
+		case "/assign":
+			return Assign, AllowedComment{i: marshalAssign(username, url)}
+		case "/unassign":
+			return Unassign, AllowedComment{i: marshalUnassign(username, url)}
+		case "/queue":
+			return Queue, AllowedComment{i: marshalUnassign(username, url)}
+		case "/extend":
+			return Extend, AllowedComment{i: marshalExtend(username, url, numbers[0])}
+		}
+		return NoAction, AllowedComment{}
+	}
+
+	switch name {
+	case "/bounty":
+		return BountyComment, AllowedComment{b: marshalAmt(users[0], numbers[0], "BOUNTY", url)}
+	case "/penalty":
+		return PenaltyComment, AllowedComment{b: marshalAmt(users[0], numbers[0], "PENALTY", url)}
+	case "/assign", "/unassign":
+		data := marshalAssign(users[0], url)
+		commentType := Assign
+		if name == "/unassign" {
+			data = marshalUnassign(users[0], url)
+			commentType = Unassign
+		}
+		data.ActedBy = username
+		return commentType, AllowedComment{i: data}
+	case "/undo":
+		return Undo, AllowedComment{u: undoRequest{Maintainer: username, Url: url}}
+	case "/extend":
+		// /extend @participant approves a pending request, while
+		// /extend <days> @participant grants the days directly
+		days := 0
+		if len(numbers) > 0 {
+			days = numbers[0]
+		}
+		data := marshalExtend(users[0], url, days)
+		data.ApprovedBy = username
+		return Extend, AllowedComment{i: data}
+	}
+
+	badges := map[string]Comment{
+		"/help":   HelpComment,
+		"/doc":    DocComment,
+		"/test":   TestComment,
+		"/impact": ImpactComment,
+		"/bug":    BugReport,
+	}
+	if commentType, ok := badges[name]; ok {
+		data := marshalAchievement(users[0], strings.ToUpper(name[1:]), url)
+		return commentType, AllowedComment{a: data}
+	}
+	return NoAction, AllowedComment{}
+}
+
+// usage describes the accepted forms of a command for error messages
+func usage(forms [][]argKind) string {
+	var described []string
+	for _, form := range forms {
+		var args []string
+		for _, kind := range form {
+			switch kind {
+			case argNumber:
+				args = append(args, "<number>")
+			case argUser:
+				args = append(args, "@<username>")
+			}
+		}
+		if len(args) == 0 {
+			described = append(described, "no arguments")
+			continue
+		}
+		described = append(described, strings.Join(args, " "))
+	}
+	return strings.Join(described, " or ")
+}
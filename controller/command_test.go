@@ -0,0 +1,216 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testIssue = "https://github.com/o/r/issues/1"
+
+func TestParseCommands(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		by   Commentator
+		want []Command
+	}{
+		{
+			name: "participant assign",
+			body: "/assign",
+			by:   Participant,
+			want: []Command{{Name: "/assign", Type: Assign, Line: 1, Column: 1,
+				Data: AllowedComment{i: marshalAssign("octocat", testIssue)}}},
+		},
+		{
+			name: "longer word is not a command",
+			body: "/assignment please",
+			by:   Participant,
+		},
+		{
+			name: "surrounding whitespace and tabs",
+			body: "\t /extend\t\t3  \r\n",
+			by:   Participant,
+			want: []Command{{Name: "/extend", Type: Extend, Line: 1, Column: 3,
+				Data: AllowedComment{i: marshalExtend("octocat", testIssue, 3)}}},
+		},
+		{
+			name: "command is case insensitive",
+			body: "/QUEUE",
+			by:   Participant,
+			want: []Command{{Name: "/queue", Type: Queue, Line: 1, Column: 1,
+				Data: AllowedComment{i: marshalUnassign("octocat", testIssue)}}},
+		},
+		{
+			name: "bounty with extra spaces",
+			body: "/bounty 50  @alice",
+			by:   Maintainer,
+			want: []Command{{Name: "/bounty", Type: BountyComment, Line: 1, Column: 1,
+				Data: AllowedComment{b: marshalAmt("alice", 50, "BOUNTY", testIssue)}}},
+		},
+		{
+			name: "one command per line",
+			body: "Thanks for the fix!\n/bounty 50 @alice\n/doc @alice\n",
+			by:   Maintainer,
+			want: []Command{
+				{Name: "/bounty", Type: BountyComment, Line: 2, Column: 1,
+					Data: AllowedComment{b: marshalAmt("alice", 50, "BOUNTY", testIssue)}},
+				{Name: "/doc", Type: DocComment, Line: 3, Column: 1,
+					Data: AllowedComment{a: marshalAchievement("alice", "DOC", testIssue)}},
+			},
+		},
+		{
+			name: "quoted reply is ignored",
+			body: "> /bounty 500 @alice\n/penalty 5 @bob",
+			by:   Maintainer,
+			want: []Command{{Name: "/penalty", Type: PenaltyComment, Line: 2, Column: 1,
+				Data: AllowedComment{b: marshalAmt("bob", 5, "PENALTY", testIssue)}}},
+		},
+		{
+			name: "fenced code is ignored",
+			body: "```\n/bounty 500 @alice\n```\n~~~sh\n/bug @alice\n~~~\n",
+			by:   Maintainer,
+		},
+		{
+			name: "maintainer extend approval",
+			body: "/extend @alice",
+			by:   Maintainer,
+			want: []Command{{Name: "/extend", Type: Extend, Line: 1, Column: 1,
+				Data: AllowedComment{i: func() IssueAction {
+					data := marshalExtend("alice", testIssue, 0)
+					data.ApprovedBy = "octocat"
+					return data
+				}()}}},
+		},
+		{
+			name: "maintainer assign",
+			body: "/assign @alice",
+			by:   Maintainer,
+			want: []Command{{Name: "/assign", Type: Assign, Line: 1, Column: 1,
+				Data: AllowedComment{i: func() IssueAction {
+					data := marshalAssign("alice", testIssue)
+					data.ActedBy = "octocat"
+					return data
+				}()}}},
+		},
+		{
+			name: "maintainer undo",
+			body: "/undo",
+			by:   Maintainer,
+			want: []Command{{Name: "/undo", Type: Undo, Line: 1, Column: 1,
+				Data: AllowedComment{u: undoRequest{Maintainer: "octocat", Url: testIssue}}}},
+		},
+		{
+			name: "participant cannot use maintainer commands",
+			body: "/bounty 50 @octocat",
+			by:   Participant,
+		},
+		{
+			name: "unknown users are ignored",
+			body: "/assign",
+			by:   UnknownUser,
+		},
+		{
+			name: "paths are not commands",
+			body: "/usr/bin/env is missing",
+			by:   Maintainer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := parseCommands(tt.body, tt.by, "octocat", testIssue)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCommandsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		by     Commentator
+		want   CommandError
+		parsed int
+	}{
+		{
+			name: "amount is not a number",
+			body: "/bounty abc @alice",
+			by:   Maintainer,
+			want: CommandError{Command: "/bounty", Line: 1, Column: 9, Reason: ReasonInvalidNumber},
+		},
+		{
+			name: "negative amount",
+			body: "/penalty -5 @alice",
+			by:   Maintainer,
+			want: CommandError{Command: "/penalty", Line: 1, Column: 10, Reason: ReasonInvalidNumber},
+		},
+		{
+			name: "missing username",
+			body: "\n/bounty 50",
+			by:   Maintainer,
+			want: CommandError{Command: "/bounty", Line: 2, Column: 11, Reason: ReasonMissingArgument},
+		},
+		{
+			name: "invalid username",
+			body: "/doc @not_a_user",
+			by:   Maintainer,
+			want: CommandError{Command: "/doc", Line: 1, Column: 6, Reason: ReasonInvalidUsername},
+		},
+		{
+			name: "trailing text",
+			body: "/assign please",
+			by:   Participant,
+			want: CommandError{Command: "/assign", Line: 1, Column: 9, Reason: ReasonUnexpectedArgument},
+		},
+		{
+			name: "extend without days",
+			body: "/extend",
+			by:   Participant,
+			want: CommandError{Command: "/extend", Line: 1, Column: 8, Reason: ReasonMissingArgument},
+		},
+		{
+			name: "maintainer extend without mention",
+			body: "/extend 5",
+			by:   Maintainer,
+			want: CommandError{Command: "/extend", Line: 1, Column: 9, Reason: ReasonInvalidUsername},
+		},
+		{
+			name: "undo takes no arguments",
+			body: "/undo @alice",
+			by:   Maintainer,
+			want: CommandError{Command: "/undo", Line: 1, Column: 7, Reason: ReasonUnexpectedArgument},
+		},
+		{
+			name:   "other lines still parse",
+			body:   "/bounty 50 @alice\n/bounty fifty @bob",
+			by:     Maintainer,
+			want:   CommandError{Command: "/bounty", Line: 2, Column: 9, Reason: ReasonInvalidNumber},
+			parsed: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := parseCommands(tt.body, tt.by, "octocat", testIssue)
+			if len(got) != tt.parsed {
+				t.Errorf("got %d commands, want %d", len(got), tt.parsed)
+			}
+			if len(errs) != 1 {
+				t.Fatalf("got %d errors, want 1: %v", len(errs), errs)
+			}
+			err := *errs[0]
+			if err.Message == "" {
+				t.Error("error has no message")
+			}
+			err.Message = ""
+			if err != tt.want {
+				t.Errorf("got %+v, want %+v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/IAmRiteshKoushik/alfred/schemas"
+	"github.com/IAmRiteshKoushik/alfred/worker"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Payload struct published for every event type
+var eventPayloads = map[string]any{
+	pkg.IssueActionEvent:     IssueAction{},
+	pkg.BountyActionEvent:    BountyAction{},
+	pkg.AchievementEvent:     Achievement{},
+	pkg.SolutionEvent:        Solution{},
+	pkg.LiveUpdateEvent:      LiveUpdate{},
+	pkg.DeadLetterEvent:      worker.DeadLetter{},
+	pkg.CommandRejectedEvent: CommandRejected{},
+	pkg.ReversalEvent:        Reversal{},
+	pkg.ClaimStatusEvent:     ClaimStatus{},
+	pkg.ClaimReminderEvent:   ClaimReminder{},
+}
+
+type schema struct {
+	Properties map[string]json.RawMessage `json:"properties"`
+	Required   []string                   `json:"required"`
+}
+
+type typeEnum struct {
+	Enum []string `json:"enum"`
+}
+
+func loadSchema(t *testing.T, name string) schema {
+	t.Helper()
+	data, err := schemas.FS.ReadFile(name)
+	if err != nil {
+		t.Fatalf("missing schema %s: %v", name, err)
+	}
+	var s schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatalf("invalid schema %s: %v", name, err)
+	}
+	return s
+}
+
+// jsonFields returns the json names of a struct's fields and whether each
+// one is omitted when empty
+func jsonFields(v any) map[string]bool {
+	fields := map[string]bool{}
+	rt := reflect.TypeOf(v)
+	for i := range rt.NumField() {
+		tag := rt.Field(i).Tag.Get("json")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fields[name] = strings.Contains(opts, "omitempty")
+	}
+	return fields
+}
+
+func TestEveryEventTypeHasSchema(t *testing.T) {
+	for eventType, version := range pkg.EventVersions {
+		if _, ok := eventPayloads[eventType]; !ok {
+			t.Errorf("no payload registered in test for %s", eventType)
+		}
+		loadSchema(t, fmt.Sprintf("%s.v%d.json", eventType, version))
+	}
+}
+
+func TestPayloadsMatchSchemas(t *testing.T) {
+	for eventType, payload := range eventPayloads {
+		name := fmt.Sprintf("%s.v%d.json", eventType, pkg.EventVersions[eventType])
+		t.Run(name, func(t *testing.T) {
+			s := loadSchema(t, name)
+			fields := jsonFields(payload)
+
+			for field := range fields {
+				if _, ok := s.Properties[field]; !ok {
+					t.Errorf("field %s is not described by the schema", field)
+				}
+			}
+			for property := range s.Properties {
+				if _, ok := fields[property]; !ok {
+					t.Errorf("schema property %s is not in the payload", property)
+				}
+			}
+			for field, omitEmpty := range fields {
+				if !omitEmpty && !slices.Contains(s.Required, field) {
+					t.Errorf("field %s is always sent but not required", field)
+				}
+			}
+		})
+	}
+}
+
+func TestEnvelopeFieldsMatchSchema(t *testing.T) {
+	s := loadSchema(t, "envelope.json")
+	event, err := pkg.NewEnvelope(context.Background(), pkg.IssueActionEvent, "delivery",
+		marshalAssign("octocat", "https://github.com/o/r/issues/1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var types typeEnum
+	if err := json.Unmarshal(s.Properties["type"], &types); err != nil {
+		t.Fatal(err)
+	}
+	for eventType := range pkg.EventVersions {
+		if !slices.Contains(types.Enum, eventType) {
+			t.Errorf("event type %s is missing from the envelope schema", eventType)
+		}
+	}
+
+	fields := event.Fields()
+	for field := range fields {
+		if !slices.Contains(s.Required, field) {
+			t.Errorf("stream field %s is not required by the schema", field)
+		}
+	}
+	for _, field := range s.Required {
+		if _, ok := fields[field]; !ok {
+			t.Errorf("required field %s missing from stream entry", field)
+		}
+	}
+
+	var got IssueAction
+	if err := json.Unmarshal([]byte(fields["payload"].(string)), &got); err != nil {
+		t.Fatalf("payload is not valid JSON: %v", err)
+	}
+	if got.ParticipantUsername != "octocat" || !got.Claimed {
+		t.Errorf("payload did not round trip, got %+v", got)
+	}
+}
+
+func TestEnvelopeCarriesTraceparent(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	provider := sdktrace.NewTracerProvider()
+	defer provider.Shutdown(context.Background())
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "dispatch")
+	defer span.End()
+
+	event, err := pkg.NewEnvelope(ctx, pkg.IssueActionEvent, "delivery",
+		marshalAssign("octocat", "https://github.com/o/r/issues/1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	traceparent, ok := event.Fields()["traceparent"].(string)
+	if !ok {
+		t.Fatal("traceparent missing from stream entry")
+	}
+	if _, ok := loadSchema(t, "envelope.json").Properties["traceparent"]; !ok {
+		t.Error("traceparent is not described by the envelope schema")
+	}
+
+	// A consumer continuing from the entry ends up in the same trace
+	continued := trace.SpanContextFromContext(
+		pkg.ContextWithTraceparent(context.Background(), traceparent))
+	if continued.TraceID() != span.SpanContext().TraceID() {
+		t.Errorf("trace id %s does not match %s", continued.TraceID(), span.SpanContext().TraceID())
+	}
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IAmRiteshKoushik/alfred/cmd"
+	db "github.com/IAmRiteshKoushik/alfred/db/gen"
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/IAmRiteshKoushik/alfred/worker"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// withinExtensionLimits reports whether a participant can extend the claim by
+// days without asking a maintainer first
+func withinExtensionLimits(claim db.IssueClaim, days int) bool {
+	return int(claim.ExtensionCount) < cmd.AppConfig.MaxExtensions &&
+		int(claim.ExtendedDays)+days <= cmd.AppConfig.MaxExtensionDays
+}
+
+// extendClaim handles /extend. A participant gets the extension straight away
+// as long as the claim stays within the configured limits, anything beyond
+// is parked on the claim until a maintainer approves it. Maintainers either
+// grant a number of days directly or approve what was parked.
+func extendClaim(ctx context.Context, action IssueAction, source commentSource,
+	deliveryId string) (string, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	tx, err := cmd.BeginTx(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = markLineHandled(ctx, tx, source); err != nil {
+		return "", err
+	}
+
+	q := db.New()
+	claim, err := q.GetActiveClaimQuery(ctx, tx, db.GetActiveClaimQueryParams{
+		Ghusername: action.ParticipantUsername,
+		IssueUrl:   action.Url,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", &CommandError{
+			Reason:  ReasonNoActiveClaim,
+			Message: action.ParticipantUsername + " has no active claim on this issue",
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch claim: %w", err)
+	}
+
+	switch {
+	case action.ApprovedBy != "" && action.Days == 0:
+		if !claim.PendingExtensionDays.Valid {
+			return "", &CommandError{
+				Reason:  ReasonNoPendingExtension,
+				Message: "No extension is waiting for approval",
+			}
+		}
+		action.Days = int(claim.PendingExtensionDays.Int32)
+	case action.ApprovedBy == "" && !withinExtensionLimits(claim, action.Days):
+		action.PendingApproval = true
+	}
+
+	if action.PendingApproval {
+		err = q.RequestClaimExtensionQuery(ctx, tx, db.RequestClaimExtensionQueryParams{
+			PendingExtensionDays: pgtype.Int4{Int32: int32(action.Days), Valid: true},
+			ID:                   claim.ID,
+		})
+	} else {
+		_, err = q.ExtendClaimQuery(ctx, tx, db.ExtendClaimQueryParams{
+			Days: int32(action.Days),
+			ID:   claim.ID,
+		})
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to extend claim: %w", err)
+	}
+
+	// DevPool lets the participant know whether the extension went through
+	// or is waiting on a maintainer
+	err = queueStream(ctx, tx, pkg.IssueClaim, pkg.IssueActionEvent, deliveryId, action)
+	if err != nil {
+		return "", fmt.Errorf("failed to queue issue action: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	worker.NotifyOutbox()
+
+	if action.PendingApproval {
+		return fmt.Sprintf("Extension of %d days is waiting for maintainer approval", action.Days), nil
+	}
+	return fmt.Sprintf("Claim extended by %d days", action.Days), nil
+}
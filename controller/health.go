@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/IAmRiteshKoushik/alfred/bootstrap"
+	"github.com/IAmRiteshKoushik/alfred/cmd"
+	db "github.com/IAmRiteshKoushik/alfred/db/gen"
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// Status of a single dependency in the readiness report
+const (
+	StatusUp      = "up"
+	StatusDown    = "down"
+	StatusLagging = "lagging"
+)
+
+type HealthCheck struct {
+	Status    string         `json:"status"`
+	LatencyMs float64        `json:"latency_ms"`
+	Error     string         `json:"error,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// HealthzHandler only tells the orchestrator that the process is alive, it
+// does not touch any dependency.
+func HealthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "alive",
+	})
+}
+
+// ReadyzHandler checks every dependency concurrently. The service is ready
+// when Postgres and Valkey respond and all bootstrapped structures exist with
+// the right type. Outbox and queue lag are reported but do not make the
+// service unready, another replica would be working off the same backlog.
+func ReadyzHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	checks := map[string]func(context.Context) (map[string]any, string, error){
+		"postgres":   checkPostgres,
+		"valkey":     checkValkey,
+		"structures": checkStructures,
+		"outbox":     checkOutbox,
+		"queue":      checkQueue,
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]HealthCheck, len(checks))
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			details, status, err := check(ctx)
+			result := HealthCheck{
+				Status:    status,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+				Details:   details,
+			}
+			if err != nil {
+				result.Status = StatusDown
+				result.Error = err.Error()
+			}
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	ready := true
+	for _, result := range results {
+		if result.Status == StatusDown {
+			ready = false
+		}
+	}
+	if !ready {
+		pkg.Log.Warn(c, "Readiness check failed")
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "not ready",
+			"checks": results,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ready",
+		"checks": results,
+	})
+}
+
+func checkPostgres(ctx context.Context) (map[string]any, string, error) {
+	if err := cmd.DBPool.Ping(ctx); err != nil {
+		return nil, StatusDown, err
+	}
+	stat := cmd.DBPool.Stat()
+	return map[string]any{
+		"total_conns":    stat.TotalConns(),
+		"acquired_conns": stat.AcquiredConns(),
+	}, StatusUp, nil
+}
+
+func checkValkey(ctx context.Context) (map[string]any, string, error) {
+	if err := pkg.Valkey.Ping(ctx).Err(); err != nil {
+		return nil, StatusDown, err
+	}
+	return nil, StatusUp, nil
+}
+
+// checkStructures compares the type of every bootstrapped key against the one
+// it was created with. A key that was deleted or overwritten shows up here.
+func checkStructures(ctx context.Context) (map[string]any, string, error) {
+	pipe := pkg.Valkey.Pipeline()
+	types := make([]*redis.StatusCmd, len(bootstrap.Structures))
+	for i, s := range bootstrap.Structures {
+		types[i] = pipe.Type(ctx, s.Name)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, StatusDown, err
+	}
+
+	var broken []string
+	for i, s := range bootstrap.Structures {
+		if got := types[i].Val(); got != s.Type {
+			broken = append(broken, s.Name+" is "+got+", expected "+s.Type)
+		}
+	}
+	details := map[string]any{
+		"checked": len(bootstrap.Structures),
+	}
+	if len(broken) > 0 {
+		details["broken"] = broken
+		return details, StatusDown, nil
+	}
+	return details, StatusUp, nil
+}
+
+func checkOutbox(ctx context.Context) (map[string]any, string, error) {
+	lag, err := db.New().GetOutboxLagQuery(ctx, cmd.DBPool)
+	if err != nil {
+		return nil, StatusDown, err
+	}
+	return lagDetails(lag.Pending, lag.OldestAge, cmd.AppConfig.MaxOutboxLag)
+}
+
+func checkQueue(ctx context.Context) (map[string]any, string, error) {
+	lag, err := db.New().GetQueueLagQuery(ctx, cmd.DBPool)
+	if err != nil {
+		return nil, StatusDown, err
+	}
+	return lagDetails(lag.Pending, lag.OldestAge, cmd.AppConfig.MaxQueueLag)
+}
+
+func lagDetails(pending int64, oldestAge float64, limit time.Duration) (map[string]any, string, error) {
+	status := StatusUp
+	if oldestAge > limit.Seconds() {
+		status = StatusLagging
+	}
+	return map[string]any{
+		"pending":            pending,
+		"oldest_age_seconds": oldestAge,
+	}, status, nil
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"slices"
@@ -12,8 +13,10 @@ import (
 	"github.com/IAmRiteshKoushik/alfred/cmd"
 	db "github.com/IAmRiteshKoushik/alfred/db/gen"
 	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/IAmRiteshKoushik/alfred/worker"
 	"github.com/gin-gonic/gin"
 	"github.com/google/go-github/v74/github"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -40,7 +43,7 @@ func handleIssueEvent(c *gin.Context, payload any) {
 			issueAccepted(c, title, repoUrl, *issueEvent.Issue.HTMLURL)
 			return
 		}
-		if slices.Contains([]string{"EASY", "MEDIUM", "HARD"}, label) {
+		if slices.Contains(cmd.Difficulties, label) {
 			updateIssueDifficulty(c, *issueEvent.Issue.HTMLURL, label)
 			return
 		}
@@ -76,10 +79,10 @@ func handleIssueEvent(c *gin.Context, payload any) {
 }
 
 func issueAccepted(c *gin.Context, title, repoUrl string, url string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	tx, err := cmd.DBPool.Begin(ctx)
+	tx, err := cmd.BeginTx(ctx)
 	if err != nil {
 		pkg.Log.Error(c, "Failed to begin transaction", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -122,6 +125,17 @@ func issueAccepted(c *gin.Context, title, repoUrl string, url string) {
 		return
 	}
 
+	update := newLiveUpdate(LiveIssueAccepted, "", url)
+	update.Title = title
+	update.RepoUrl = repoUrl
+	if err = queueLiveUpdate(ctx, tx, pkg.GrabDeliveryId(c), update); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to add new issue",
+		})
+		pkg.Log.Error(c, "Failed to queue live update", err)
+		return
+	}
+
 	if err = tx.Commit(ctx); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "Failed to commit transaction",
@@ -129,6 +143,7 @@ func issueAccepted(c *gin.Context, title, repoUrl string, url string) {
 		pkg.Log.Fatal(c, "Failed to commit transaction", err)
 		return
 	}
+	worker.NotifyOutbox()
 
 	pkg.Log.Info(c, "Successfully added new issue: "+title)
 	c.JSON(http.StatusOK, gin.H{
@@ -138,10 +153,10 @@ func issueAccepted(c *gin.Context, title, repoUrl string, url string) {
 
 func updateIssueDifficulty(c *gin.Context, issueUrl string, difficulty string) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	tx, err := cmd.DBPool.Begin(ctx)
+	tx, err := cmd.BeginTx(ctx)
 	if err != nil {
 		pkg.Log.Error(c, "Failed to begin transaction", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -165,6 +180,23 @@ func updateIssueDifficulty(c *gin.Context, issueUrl string, difficulty string) {
 		return
 	}
 
+	// Claims that are already running get the deadline of the new difficulty,
+	// counted from when they were claimed and keeping their extensions
+	moved, err := q.RescheduleClaimsQuery(ctx, tx, db.RescheduleClaimsQueryParams{
+		Days:     int32(cmd.AppConfig.ClaimDaysFor(difficulty)),
+		IssueUrl: issueUrl,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to reschedule claims",
+		})
+		pkg.Log.Error(c, "Failed to reschedule claims", err)
+		return
+	}
+	if moved > 0 {
+		pkg.Log.Info(c, fmt.Sprintf("Rescheduled %d claims for difficulty %s", moved, difficulty))
+	}
+
 	if err = tx.Commit(ctx); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "Failed to commit transaction",
@@ -181,10 +213,10 @@ func updateIssueDifficulty(c *gin.Context, issueUrl string, difficulty string) {
 
 func issueTagUpdate(c *gin.Context, issueUrl string, tag string) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	tx, err := cmd.DBPool.Begin(ctx)
+	tx, err := cmd.BeginTx(ctx)
 	if err != nil {
 		pkg.Log.Error(c, "Failed to begin transaction", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -235,10 +267,10 @@ func updateIssueBounty(c *gin.Context, issueUrl string, bounty string) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	tx, err := cmd.DBPool.Begin(ctx)
+	tx, err := cmd.BeginTx(ctx)
 	if err != nil {
 		pkg.Log.Error(c, "Failed to begin transaction", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -278,9 +310,9 @@ func updateIssueBounty(c *gin.Context, issueUrl string, bounty string) {
 
 func issueUserAction(c *gin.Context, username string, url string, action string) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
-	tx, err := cmd.DBPool.Begin(ctx)
+	tx, err := cmd.BeginTx(ctx)
 	if err != nil {
 		pkg.Log.Error(c, "Failed to begin transaction", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -306,18 +338,70 @@ func issueUserAction(c *gin.Context, username string, url string, action string)
 
 	switch action {
 	case "assigned":
+		// Assignments on one issue are recorded one at a time, only the
+		// participant holding the issue gets the claim
+		if err = q.LockIssueClaimQuery(ctx, tx, url); err != nil {
+			pkg.Log.Error(c, "Failed to lock issue claim", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		claimant, err := q.GetIssueClaimantQuery(ctx, tx, url)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			pkg.Log.Error(c, "Failed to fetch claimant", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if claimant != "" && claimant != username {
+			err = refuseAssignment(ctx, tx, username, url, claimant, pkg.GrabDeliveryId(c))
+			if err == nil {
+				err = tx.Commit(ctx)
+			}
+			if err != nil {
+				pkg.Log.Error(c, "Failed to refuse assignment", err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			worker.NotifyOutbox()
+			pkg.Log.Info(c, "Issue is held by "+claimant+", "+username+" was waitlisted")
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Issue is already claimed, assignee was waitlisted",
+			})
+			return
+		}
+
+		difficulty, err := issueDifficulty(ctx, tx, url)
+		if err != nil {
+			pkg.Log.Error(c, "Failed to fetch issue difficulty", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		days := cmd.AppConfig.ClaimDaysFor(difficulty)
+		now := time.Now()
 		params := db.IssueAssignQueryParams{
 			IssueUrl:   url,
 			Ghusername: username,
-			ClaimedOn:  pgtype.Timestamp{Time: time.Now(), Valid: true},
-			ElapsedOn:  pgtype.Timestamp{Time: time.Now().Add(8 * 24 * time.Hour), Valid: true},
+			ClaimedOn:  pgtype.Timestamp{Time: now, Valid: true},
+			ElapsedOn:  pgtype.Timestamp{Time: now.AddDate(0, 0, days), Valid: true},
 		}
-		err = q.IssueAssignQuery(ctx, tx, params)
+		assigned, err := q.IssueAssignQuery(ctx, tx, params)
 		if err != nil {
 			pkg.Log.Error(c, "Failed to assign issue", err)
 			c.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
+		if assigned == 0 {
+			pkg.Log.Info(c, "Claim of "+username+" is already recorded")
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Claim was already recorded",
+			})
+			return
+		}
+		// The claim is recorded now, it no longer needs holding
+		if err = q.ClearPendingClaimQuery(ctx, tx, url); err != nil {
+			pkg.Log.Error(c, "Failed to clear pending claim", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
 
 	case "unassigned":
 		params := db.IssueUnassignQueryParams{
@@ -331,8 +415,17 @@ func issueUserAction(c *gin.Context, username string, url string, action string)
 			return
 		}
 		if ok == "" {
-			pkg.Log.Warn(c, "Issue not found or user not assigned")
-			c.AbortWithStatus(http.StatusNotFound)
+			// Usually the claim sweeper released it already and this is
+			// DevPool unassigning the participant on GitHub
+			pkg.Log.Info(c, "No claim left to release for "+username)
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Claim was already released",
+			})
+			return
+		}
+		if err = promoteFromWaitlist(ctx, tx, url, pkg.GrabDeliveryId(c)); err != nil {
+			pkg.Log.Error(c, "Failed to promote from waitlist", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
 
@@ -352,6 +445,22 @@ func issueUserAction(c *gin.Context, username string, url string, action string)
 		return
 	}
 
+	// The claim only becomes real once DevPool has acted on the /assign or
+	// /unassign comment and GitHub reports the (un)assignment, so the live
+	// update is sent from here rather than from the comment handler.
+	liveType := LiveIssueClaimed
+	if action == "unassigned" {
+		liveType = LiveIssueUnclaimed
+	}
+	if err = queueLiveUpdate(ctx, tx, pkg.GrabDeliveryId(c),
+		newLiveUpdate(liveType, username, url)); err != nil {
+		pkg.Log.Error(c, "Failed to queue live update", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
 	if err = tx.Commit(ctx); err != nil {
 		pkg.Log.Error(c, "Failed to commit transaction", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -359,6 +468,7 @@ func issueUserAction(c *gin.Context, username string, url string, action string)
 		})
 		return
 	}
+	worker.NotifyOutbox()
 
 	pkg.Log.Info(c, "User "+action+" successfully.")
 	c.JSON(http.StatusOK, gin.H{
@@ -368,10 +478,10 @@ func issueUserAction(c *gin.Context, username string, url string, action string)
 
 // Issue: CLOSED, REOPENED
 func issueStateChangeAction(c *gin.Context, url string, state string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	tx, err := cmd.DBPool.Begin(ctx)
+	tx, err := cmd.BeginTx(ctx)
 	if err != nil {
 		pkg.Log.Error(c, "Failed to begin transaction", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -386,6 +496,13 @@ func issueStateChangeAction(c *gin.Context, url string, state string) {
 	switch state {
 	case "closed":
 		_, err = q.CloseIssueQuery(c, tx, url)
+		if err == nil {
+			// Nobody gets to take over a closed issue
+			_, err = q.ClearWaitlistQuery(ctx, tx, url)
+		}
+		if err == nil {
+			err = q.ClearPendingClaimQuery(ctx, tx, url)
+		}
 	case "reopened":
 		_, err = q.OpenIssueQuery(c, tx, url)
 	default:
@@ -2,19 +2,20 @@ package controller
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"slices"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/IAmRiteshKoushik/alfred/cmd"
 	db "github.com/IAmRiteshKoushik/alfred/db/gen"
 	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/IAmRiteshKoushik/alfred/worker"
 	"github.com/gin-gonic/gin"
 	"github.com/google/go-github/v74/github"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -39,13 +40,47 @@ const (
 
 	Assign
 	Unassign
-	// Extend
+	Extend
+	Queue
+	Undo
 
 	NoAction
 )
 
-func findCommentator(username string, repoUrl string) (Commentator, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// String names the command, it is used as a metric label
+func (c Comment) String() string {
+	switch c {
+	case BountyComment:
+		return "bounty"
+	case PenaltyComment:
+		return "penalty"
+	case TestComment:
+		return "test"
+	case HelpComment:
+		return "help"
+	case DocComment:
+		return "doc"
+	case ImpactComment:
+		return "impact"
+	case BugReport:
+		return "bug"
+	case Assign:
+		return "assign"
+	case Unassign:
+		return "unassign"
+	case Extend:
+		return "extend"
+	case Queue:
+		return "queue"
+	case Undo:
+		return "undo"
+	default:
+		return "none"
+	}
+}
+
+func findCommentator(ctx context.Context, username string, repoUrl string) (Commentator, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	conn, err := cmd.DBPool.Acquire(ctx)
@@ -85,7 +120,26 @@ type IssueAction struct {
 	ParticipantUsername string `json:"github_username"`
 	Url                 string `json:"url"`
 	Claimed             bool   `json:"claimed"`
-	// Extend              bool   `json:"extend"`
+
+	// Set on /extend. PendingApproval is set while the extension waits for a
+	// maintainer, ApprovedBy once a maintainer has granted it.
+	Extend          bool   `json:"extend,omitempty"`
+	Days            int    `json:"days,omitempty"`
+	PendingApproval bool   `json:"pending_approval,omitempty"`
+	ApprovedBy      string `json:"approved_by,omitempty"`
+
+	// Set by the claim sweeper when it releases a claim that ran past its
+	// deadline
+	Expired  bool   `json:"expired,omitempty"`
+	Deadline string `json:"deadline,omitempty"`
+
+	// Set when the participant moves up the waitlist to take over a
+	// released claim
+	Promoted bool `json:"promoted,omitempty"`
+
+	// Maintainer who handed out or took away the claim with /assign
+	// @participant or /unassign @participant
+	ActedBy string `json:"acted_by,omitempty"`
 }
 
 // Serialize the data and drop it inside Redis
@@ -105,39 +159,15 @@ func marshalUnassign(username string, issueUrl string) IssueAction {
 	}
 }
 
-/*
-func handleExtension(username string, days int, issueUrl string) (IssueAction, error) {
-	fmt.Println(username, days, issueUrl)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	tx, err := cmd.DBPool.Begin(ctx)
-	if err != nil {
-		return IssueAction{}, err
-	}
-	defer tx.Rollback(ctx)
-
-	q := db.New()
-	ok, err := q.ExtendClaimQuery(ctx, tx, db.ExtendClaimQueryParams{
-		Ghusername: username,
-		IssueUrl:   issueUrl,
-		Days:       int32(days),
-	})
-	if err != nil || ok == "" {
-		return IssueAction{}, err
-	}
-
-	if err := tx.Commit(ctx); err != nil {
-		return IssueAction{}, err
-	}
-
+func marshalExtend(username string, issueUrl string, days int) IssueAction {
 	return IssueAction{
 		ParticipantUsername: username,
 		Url:                 issueUrl,
-		Claimed:             false,
+		Claimed:             true,
 		Extend:              true,
-	}, err
+		Days:                days,
+	}
 }
-*/
 
 // Bounties and penalties
 type BountyAction struct {
@@ -171,11 +201,12 @@ func marshalAchievement(username string, action string, url string) Achievement
 	}
 }
 
-func processBountyOrPenalty(bountyData BountyAction, dispatchedBy string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func processBountyOrPenalty(ctx context.Context, bountyData BountyAction,
+	dispatchedBy string, source commentSource, deliveryId string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	tx, err := cmd.DBPool.Begin(ctx)
+	tx, err := cmd.BeginTx(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -192,11 +223,17 @@ func processBountyOrPenalty(bountyData BountyAction, dispatchedBy string) error
 		Bounty:     amount,
 		Ghusername: pgtype.Text{String: bountyData.ParticipantUsername, Valid: true},
 	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &CommandError{
+			Reason:  ReasonUnknownParticipant,
+			Message: bountyData.ParticipantUsername + " is not registered for the season",
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("failed to update user bounty: %w", err)
 	}
 
-	err = q.AddBountyLogQuery(ctx, tx, db.AddBountyLogQueryParams{
+	logId, err := q.AddBountyLogQuery(ctx, tx, db.AddBountyLogQueryParams{
 		Ghusername:   bountyData.ParticipantUsername,
 		DispatchedBy: dispatchedBy,
 		ProofUrl:     bountyData.Url,
@@ -205,111 +242,127 @@ func processBountyOrPenalty(bountyData BountyAction, dispatchedBy string) error
 	if err != nil {
 		return fmt.Errorf("failed to add bounty log: %w", err)
 	}
-
-	if err = tx.Commit(ctx); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	err = recordCommand(ctx, tx, source, bountyData.Url, bountyData.ParticipantUsername,
+		dispatchedBy, pgtype.Int4{Int32: logId, Valid: true})
+	if err != nil {
+		return err
 	}
 
-	err = cmd.UpdateLeaderboard(pkg.Valkey, pkg.Leaderboard, bountyData.ParticipantUsername, float64(amount))
+	// Valkey writes go through the outbox so that they are published if and
+	// only if the bounty is committed
+	err = queueLeaderboard(ctx, tx, bountyData.ParticipantUsername, float64(amount))
+	if err != nil {
+		return fmt.Errorf("failed to queue leaderboard update: %w", err)
+	}
+	err = queueStream(ctx, tx, pkg.Bounty, pkg.BountyActionEvent, deliveryId, bountyData)
 	if err != nil {
-		return fmt.Errorf("failed to update leaderboard: %w", err)
+		return fmt.Errorf("failed to queue bounty event: %w", err)
+	}
+	liveType := LiveBountyDispatched
+	if bountyData.Action == "PENALTY" {
+		liveType = LivePenaltyDispatched
+	}
+	update := newLiveUpdate(liveType, bountyData.ParticipantUsername, bountyData.Url)
+	update.Amount = bountyData.Amount
+	if err = queueLiveUpdate(ctx, tx, deliveryId, update); err != nil {
+		return fmt.Errorf("failed to queue live update: %w", err)
 	}
 
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	worker.NotifyOutbox()
+
 	return nil
 }
 
 // Super struct to enforce polymorphism
 type AllowedComment struct {
-	i IssueAction  `json:"issue_action"`
-	b BountyAction `json:"bounty_action"`
-	a Achievement  `json:"achivement"`
+	i IssueAction
+	b BountyAction
+	a Achievement
+	u undoRequest
 }
 
-func parseComment(cm string, by Commentator, username string,
-	url string) (Comment, AllowedComment, error) {
-
-	cm = strings.TrimSpace(cm)
-	switch by {
-
-	case Participant:
-		if strings.HasPrefix(cm, "/assign") {
-			data := marshalAssign(username, url)
-			return Comment(Assign), AllowedComment{i: data}, nil
-		} else if strings.HasPrefix(cm, "/unassign") {
-			data := marshalUnassign(username, url)
-			return Comment(Unassign), AllowedComment{i: data}, nil
-		}
+// publishRejection passes a rejected command on to DevPool to reply to. The
+// line is marked as handled along with it, so that a comment delivered again
+// does not get a second reply.
+func publishRejection(ctx context.Context, rejection CommandRejected, source commentSource,
+	deliveryId string) error {
 
-	case Maintainer:
-		parts := strings.Split(cm, " ")
-		if len(parts) < 2 {
-			return Comment(NoAction), AllowedComment{}, nil
-		}
-
-		command := parts[0] // Contains bounty or penalty
-		args := parts[1:]   // Contains [amount] [username]
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
 
-		switch command {
-		case "/bounty", "/penalty":
-			if len(args) != 2 {
-				return Comment(NoAction), AllowedComment{}, fmt.Errorf("Invalid comment syntax for %s", command)
-			}
-			amt, err := strconv.Atoi(args[0])
-			if err != nil {
-				return Comment(NoAction), AllowedComment{}, fmt.Errorf("Invalid amount for %s", command)
-			}
-			action := "BOUNTY"
-			commentType := BountyComment
-			if command == "/penalty" {
-				action = "PENALTY"
-				commentType = PenaltyComment
-			}
-			username := strings.TrimPrefix(args[1], "@")
-			data := marshalAmt(username, amt, action, url)
-			return commentType, AllowedComment{b: data}, nil
-		case "/help", "/doc", "/test", "/impact", "/bug":
-			if len(args) != 1 {
-				return Comment(NoAction), AllowedComment{}, fmt.Errorf("Invalid comment syntax for %s", command)
-			}
-			var commentType Comment
-			switch command {
-			case "/help":
-				commentType = HelpComment
-			case "/doc":
-				commentType = DocComment
-			case "/test":
-				commentType = TestComment
-			case "/impact":
-				commentType = ImpactComment
-			case "/bug":
-				commentType = BugReport
-			}
-			username := strings.TrimPrefix(args[0], "@")
-			data := marshalAchievement(username, strings.ToUpper(command[1:]), url)
-			return commentType, AllowedComment{a: data}, nil
-		}
+	tx, err := cmd.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	return Comment(NoAction), AllowedComment{}, nil
-}
+	defer tx.Rollback(ctx)
 
-func sendToStream(c *gin.Context, streamName string, data any) error {
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		pkg.Log.Error(c, "Failed to marshal payload", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+	if err = markLineHandled(ctx, tx, source); err != nil {
 		return err
 	}
-	err = cmd.AddToStream(pkg.Valkey, streamName, string(jsonData))
+	err = queueStream(ctx, tx, pkg.CommandRejections, pkg.CommandRejectedEvent, deliveryId, rejection)
 	if err != nil {
-		pkg.Log.Error(c, "Failed to insert into Redis", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
-		return err
+		return fmt.Errorf("failed to queue rejection: %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	worker.NotifyOutbox()
 	return nil
 }
 
-// This function only handles the parsed results and sends them to appropriate
-// redis streams for further processing by gravemind or devpool
+// runCommand carries out one command and describes the outcome. Errors that
+// are the commentator's mistake come back as a *CommandError.
+func runCommand(c *gin.Context, command Command, commentBy string,
+	commentId int64) (string, error) {
+
+	ctx := c.Request.Context()
+	deliveryId := pkg.GrabDeliveryId(c)
+	data := command.Data
+	source := commentSource{CommentID: commentId, Line: command.Line, Command: command.Name}
+
+	switch command.Type {
+	case BountyComment, PenaltyComment:
+		// DB call, also queues the leaderboard and bounty-stream updates
+		if err := processBountyOrPenalty(ctx, data.b, commentBy, source, deliveryId); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s of %d for %s", strings.ToLower(data.b.Action),
+			data.b.Amount, data.b.ParticipantUsername), nil
+
+	case BugReport, DocComment, HelpComment, TestComment, ImpactComment:
+		if err := awardBadge(ctx, data.a, commentBy, source, deliveryId); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s badge for %s", data.a.Type, data.a.ParticipantUsername), nil
+
+	case Assign, Unassign:
+		// A participant's claim may be turned down or queued, a
+		// maintainer's is applied straight away
+		switch {
+		case data.i.ActedBy != "":
+			return claimByMaintainer(ctx, data.i, source, deliveryId)
+		case command.Type == Assign:
+			return claimIssue(ctx, data.i, source, deliveryId)
+		default:
+			return unclaimIssue(ctx, data.i, source, deliveryId)
+		}
+
+	case Queue:
+		return queueStatus(ctx, data.i, source, deliveryId)
+
+	case Extend:
+		// The outcome depends on the extension policy
+		return extendClaim(ctx, data.i, source, deliveryId)
+
+	case Undo:
+		return undoLastCommand(ctx, data.u, source, deliveryId)
+	}
+	return "", fmt.Errorf("no handler for %s", command.Name)
+}
+
 func handleIssueCommentEvent(c *gin.Context, payload any) {
 	issueCommentEvent, ok := payload.(*github.IssueCommentEvent)
 	if !ok {
@@ -320,10 +373,39 @@ func handleIssueCommentEvent(c *gin.Context, payload any) {
 		return
 	}
 
+	event := issueCommentEvent.GetAction()
+	switch event {
+	case "created":
+		commentCreated(c, issueCommentEvent)
+		return
+
+	case "edited":
+		// Commands run once, when the comment is made. Rewriting them is
+		// not applied, a maintainer deletes the comment to take them back.
+		pkg.Log.Info(c, "Edited comments are not run again")
+		c.JSON(http.StatusOK, gin.H{"message": "Edits to comments are not applied"})
+		return
+
+	case "deleted":
+		commentDeleted(c, issueCommentEvent)
+		return
+
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"message": "This issue-comment event-type " + event + " is not handled.",
+		})
+		pkg.Log.Warn(c, event+" is not handled.")
+		return
+	}
+}
+
+// commentCreated only handles the parsed results and sends them to appropriate
+// redis streams for further processing by gravemind or devpool
+func commentCreated(c *gin.Context, issueCommentEvent *github.IssueCommentEvent) {
 	issueUrl := *issueCommentEvent.Issue.HTMLURL
 	repoUrl := *issueCommentEvent.Repo.HTMLURL
 	commentBy := *issueCommentEvent.Comment.User.Login
-	commentator, err := findCommentator(commentBy, repoUrl)
+	commentator, err := findCommentator(c.Request.Context(), commentBy, repoUrl)
 	if err != nil {
 		pkg.Log.Error(c, "Failed to find the commentator", err)
 		c.AbortWithStatus(http.StatusBadRequest)
@@ -331,71 +413,122 @@ func handleIssueCommentEvent(c *gin.Context, payload any) {
 	}
 
 	commentBody := *issueCommentEvent.Comment.Body
-	action, result, err := parseComment(commentBody, commentator, commentBy, issueUrl)
-	if err != nil {
-		pkg.Log.Error(c, "Failed to parse issue-comment", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
-		return
-	}
-
-	// No action
-	switch action {
-
-	case NoAction:
+	commands, rejected := parseCommands(commentBody, commentator, commentBy, issueUrl)
+	if len(commands) == 0 && len(rejected) == 0 {
+		pkg.CommandsParsed.WithLabelValues(NoAction.String()).Inc()
 		pkg.Log.Info(c, "No action is being performed for issue comment")
 		c.AbortWithStatus(http.StatusOK)
 		return
+	}
 
-	case BountyComment, PenaltyComment:
-		// DB call
-		fmt.Println(result.b.ParticipantUsername)
-		err := processBountyOrPenalty(result.b, commentBy)
-		if err != nil {
-			pkg.Log.Error(c, "Failed to process bounty/penalty", err)
-			c.AbortWithStatus(http.StatusInternalServerError)
-			return
+	// Every command runs on its own, so one that is turned down does not
+	// hold back the others in the same comment. When GitHub delivers the
+	// comment again after a failure, the lines handled the first time round
+	// are skipped.
+	commentId := *issueCommentEvent.Comment.ID
+	handled, err := db.New().GetCommentLinesQuery(c.Request.Context(), cmd.DBPool, commentId)
+	if err != nil {
+		pkg.Log.Error(c, "Failed to fetch handled comment lines", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	var results []string
+	for _, command := range commands {
+		pkg.CommandsParsed.WithLabelValues(command.Type.String()).Inc()
+		if slices.Contains(handled, int32(command.Line)) {
+			results = append(results, fmt.Sprintf("%s on line %d was already applied",
+				command.Name, command.Line))
+			continue
 		}
-		// Redis call
-		if err := sendToStream(c, pkg.Bounty, result.b); err != nil {
-			return
+		result, err := runCommand(c, command, commentBy, commentId)
+		if errors.Is(err, errCommandRecorded) {
+			// GitHub redelivered a comment that was already handled
+			results = append(results, fmt.Sprintf("%s on line %d was already applied",
+				command.Name, command.Line))
+			continue
 		}
-
-	case BugReport, DocComment, HelpComment, TestComment, ImpactComment:
-		if err := sendToStream(c, pkg.AutomaticEvents, result.a); err != nil {
-			return
+		var commandErr *CommandError
+		if errors.As(err, &commandErr) {
+			commandErr.Command = command.Name
+			commandErr.Line = command.Line
+			commandErr.Column = command.Column
+			rejected = append(rejected, commandErr)
+			continue
 		}
-
-	case Assign:
-		// Redis Call
-		if err := sendToStream(c, pkg.IssueClaim, result.i); err != nil {
+		if err != nil {
+			pkg.Log.Error(c, "Failed to run "+command.Name, err)
+			c.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
+		results = append(results, result)
+	}
 
-	case Unassign:
-		// Redis call
-		if err := sendToStream(c, pkg.IssueClaim, result.i); err != nil {
-			return
+	// A rejected command is the commentator's mistake, not a failed delivery.
+	// GitHub gets a 200 and DevPool replies on the issue with the reason.
+	if len(rejected) > 0 {
+		var messages []string
+		for _, commandErr := range rejected {
+			pkg.Log.Warn(c, "Rejected "+commandErr.Error())
+			source := commentSource{
+				CommentID: commentId,
+				Line:      commandErr.Line,
+				Command:   commandErr.Command,
+			}
+			err := publishRejection(c.Request.Context(),
+				marshalRejection(commandErr, commentBy, issueUrl), source, pkg.GrabDeliveryId(c))
+			if errors.Is(err, errCommandRecorded) {
+				// DevPool already has the rejection from the first delivery
+				err = nil
+			}
+			if err != nil {
+				pkg.Log.Error(c, "Failed to publish rejection of "+commandErr.Command, err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			messages = append(messages, commandErr.Error())
 		}
-		/*
-			// Currently not being used - "/extend"
-			case Extend:
-				jsonData, err := json.Marshal(result.i)
-				if err != nil {
-					pkg.Log.Error(c, "Failed to marshal payload", err)
-					c.AbortWithStatus(http.StatusInternalServerError)
-					return
-				}
-				err = cmd.AddToStream(pkg.Valkey, pkg.IssueClaim, string(jsonData))
-				if err != nil {
-					pkg.Log.Error(c, "Failed to insert into Redis", err)
-					c.AbortWithStatus(http.StatusInternalServerError)
-					return
-				}
-		*/
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Some commands were rejected",
+			"results": results,
+			"errors":  messages,
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Issue-Comment Event handled successfully",
+		"results": results,
+	})
+	pkg.Log.Success(c)
+}
+
+// commentDeleted takes back the bounties, penalties and badges a comment
+// handed out when a maintainer deletes it
+func commentDeleted(c *gin.Context, issueCommentEvent *github.IssueCommentEvent) {
+	deletedBy := issueCommentEvent.GetSender().GetLogin()
+	repoUrl := issueCommentEvent.GetRepo().GetHTMLURL()
+	commentator, err := findCommentator(c.Request.Context(), deletedBy, repoUrl)
+	if err != nil {
+		pkg.Log.Error(c, "Failed to find who deleted the comment", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if commentator != Maintainer {
+		pkg.Log.Info(c, "Comment deleted by "+deletedBy+", nothing is reversed")
+		c.JSON(http.StatusOK, gin.H{"message": "Only deletions by maintainers are reversed"})
+		return
+	}
+
+	reversed, err := reverseComment(c.Request.Context(), issueCommentEvent.GetComment().GetID(),
+		deletedBy, pkg.GrabDeliveryId(c))
+	if err != nil {
+		pkg.Log.Error(c, "Failed to reverse deleted comment", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Reversed %d commands of the deleted comment", reversed),
 	})
 	pkg.Log.Success(c)
 }
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/jackc/pgx/v5"
+)
+
+// Event types published on the live-update-stream for the SSE feed on Pulse
+const (
+	LiveIssueAccepted     = "issue-accepted"
+	LiveIssueClaimed      = "issue-claimed"
+	LiveIssueUnclaimed    = "issue-unclaimed"
+	LiveBountyDispatched  = "bounty-dispatched"
+	LivePenaltyDispatched = "penalty-dispatched"
+	LiveBountyReversed    = "bounty-reversed"
+	LivePullRequestOpened = "pull-request-opened"
+	LivePullRequestMerged = "pull-request-merged"
+)
+
+// LiveUpdate is the payload of every live-update event. The envelope carries
+// its schema version and time, Event tells Pulse what happened.
+type LiveUpdate struct {
+	Event               string `json:"event"`
+	ParticipantUsername string `json:"github_username,omitempty"`
+	Url                 string `json:"url"`
+	RepoUrl             string `json:"repo_url,omitempty"`
+	Title               string `json:"title,omitempty"`
+	Amount              int    `json:"amount,omitempty"`
+}
+
+func newLiveUpdate(event string, username string, url string) LiveUpdate {
+	return LiveUpdate{
+		Event:               event,
+		ParticipantUsername: username,
+		Url:                 url,
+	}
+}
+
+// queueLiveUpdate adds the event to the outbox so that it only reaches the
+// leaderboard once the change it describes has been committed.
+func queueLiveUpdate(ctx context.Context, tx pgx.Tx, deliveryId string, update LiveUpdate) error {
+	return queueStream(ctx, tx, pkg.LiveUpdates, pkg.LiveUpdateEvent, deliveryId, update)
+}
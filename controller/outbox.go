@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	db "github.com/IAmRiteshKoushik/alfred/db/gen"
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+)
+
+// Handlers that write to Postgres must not talk to Valkey directly, otherwise
+// a Valkey outage after the commit leaves the two stores disagreeing. Instead
+// the Valkey writes are added to the outbox inside the same transaction and
+// published by the outbox relay once the transaction has committed.
+
+// queueStream wraps the data in an event envelope and adds it to the outbox
+func queueStream(ctx context.Context, tx db.DBTX, streamName string,
+	eventType string, deliveryId string, data any) error {
+
+	event, err := pkg.NewEnvelope(ctx, eventType, deliveryId, data)
+	if err != nil {
+		return err
+	}
+	jsonData, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+	q := db.New()
+	return q.AddOutboxEntryQuery(ctx, tx, db.AddOutboxEntryQueryParams{
+		Command: pkg.OutboxStream,
+		Key:     streamName,
+		Value:   string(jsonData),
+	})
+}
+
+// queueLeaderboard adds a leaderboard score change to the outbox
+func queueLeaderboard(ctx context.Context, tx db.DBTX, username string, increment float64) error {
+	q := db.New()
+	return q.AddOutboxEntryQuery(ctx, tx, db.AddOutboxEntryQueryParams{
+		Command:   pkg.OutboxSortedSet,
+		Key:       pkg.Leaderboard,
+		Value:     username,
+		Increment: increment,
+	})
+}
@@ -26,10 +26,10 @@ func handlePingEvent(c *gin.Context, incoming any) {
 
 	repoUrl := *payload.Repo.HTMLURL
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	tx, err := cmd.DBPool.Begin(ctx)
+	tx, err := cmd.BeginTx(ctx)
 	if err != nil {
 		pkg.Log.Error(c, "Failed to begin transaction", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -2,13 +2,13 @@ package controller
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
 	"time"
 
 	"github.com/IAmRiteshKoushik/alfred/cmd"
 	db "github.com/IAmRiteshKoushik/alfred/db/gen"
 	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/IAmRiteshKoushik/alfred/worker"
 	"github.com/gin-gonic/gin"
 	"github.com/google/go-github/v74/github"
 )
@@ -32,10 +32,10 @@ func handlePullRequestEvent(c *gin.Context, payload any) {
 	action := *prEvent.Action
 	isMerged := *prEvent.PullRequest.Merged
 
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 20*time.Second)
 	defer cancel()
 
-	tx, err := cmd.DBPool.Begin(ctx)
+	tx, err := cmd.BeginTx(ctx)
 	if err != nil {
 		pkg.Log.Fatal(c, "Could not being transaction", err)
 		c.AbortWithStatus(http.StatusInternalServerError)
@@ -57,20 +57,23 @@ func handlePullRequestEvent(c *gin.Context, payload any) {
 			c.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
-		// Redis Call
-		jsonData, err := json.Marshal(Solution{
+		// Redis Call, published by the outbox relay after commit
+		solution := Solution{
 			Username: username,
 			Url:      prUrl,
 			Merged:   false,
-		})
+		}
+		err = queueStream(ctx, tx, pkg.SolutionMerge, pkg.SolutionEvent,
+			pkg.GrabDeliveryId(c), solution)
 		if err != nil {
-			pkg.Log.Error(c, "Failed to marshal payload", err)
+			pkg.Log.Error(c, "Failed to queue solution event", err)
 			c.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
-		err = cmd.AddToStream(pkg.Valkey, pkg.SolutionMerge, string(jsonData))
-		if err != nil {
-			pkg.Log.Error(c, "Failed to insert into Redis", err)
+		live := newLiveUpdate(LivePullRequestOpened, username, prUrl)
+		live.RepoUrl = repoUrl
+		if err = queueLiveUpdate(ctx, tx, pkg.GrabDeliveryId(c), live); err != nil {
+			pkg.Log.Error(c, "Failed to queue live update", err)
 			c.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
@@ -100,26 +103,29 @@ func handlePullRequestEvent(c *gin.Context, payload any) {
 				c.AbortWithStatus(http.StatusBadRequest)
 				return
 			}
-			// Redis Call
-			jsonData, err := json.Marshal(Solution{
+			// Redis Call, published by the outbox relay after commit
+			solution := Solution{
 				Username: username,
 				Url:      prUrl,
 				Merged:   true,
-			})
+			}
+			err = queueStream(ctx, tx, pkg.SolutionMerge, pkg.SolutionEvent,
+				pkg.GrabDeliveryId(c), solution)
 			if err != nil {
-				pkg.Log.Error(c, "Failed to marshal payload", err)
+				pkg.Log.Error(c, "Failed to queue solution event", err)
 				c.AbortWithStatus(http.StatusInternalServerError)
 				return
 			}
-			err = cmd.AddToStream(pkg.Valkey, pkg.SolutionMerge, string(jsonData))
+			err = queueLeaderboard(ctx, tx, username, 0.001)
 			if err != nil {
-				pkg.Log.Error(c, "Failed to insert into Redis", err)
+				pkg.Log.Error(c, "Failed to queue leaderboard update", err)
 				c.AbortWithStatus(http.StatusInternalServerError)
 				return
 			}
-			err = cmd.UpdateLeaderboard(pkg.Valkey, pkg.Leaderboard, username, 0.001)
-			if err != nil {
-				pkg.Log.Error(c, "Failed to update leaderboard", err)
+			live := newLiveUpdate(LivePullRequestMerged, username, prUrl)
+			live.RepoUrl = repoUrl
+			if err = queueLiveUpdate(ctx, tx, pkg.GrabDeliveryId(c), live); err != nil {
+				pkg.Log.Error(c, "Failed to queue live update", err)
 				c.AbortWithStatus(http.StatusInternalServerError)
 				return
 			}
@@ -142,6 +148,7 @@ func handlePullRequestEvent(c *gin.Context, payload any) {
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
+	worker.NotifyOutbox()
 
 	pkg.Log.Success(c)
 	c.JSON(http.StatusOK, gin.H{
@@ -0,0 +1,269 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/IAmRiteshKoushik/alfred/cmd"
+	db "github.com/IAmRiteshKoushik/alfred/db/gen"
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/IAmRiteshKoushik/alfred/worker"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Why a bounty, penalty or badge was taken back
+const (
+	CauseCommentDeleted = "comment-deleted"
+	CauseUndo           = "undo"
+)
+
+// errCommandRecorded is returned when the command on a line of a comment has
+// already been handled, as happens when GitHub redelivers the comment
+var errCommandRecorded = errors.New("command was already applied")
+
+// commentSource is the line of an issue comment a command was written on
+type commentSource struct {
+	CommentID int64
+	Line      int
+	Command   string
+}
+
+// undoRequest is a maintainer's /undo on an issue
+type undoRequest struct {
+	Maintainer string
+	Url        string
+}
+
+// Reversal takes back a bounty, penalty or badge handed out through a comment.
+// It is published on the stream the original went to, Amount is the change
+// made to the participant's bounty.
+type Reversal struct {
+	ParticipantUsername string `json:"github_username"`
+	Url                 string `json:"url"`
+	Action              string `json:"action"`
+	Amount              int    `json:"amount,omitempty"`
+	ReversedBy          string `json:"reversed_by"`
+	Cause               string `json:"cause"`
+}
+
+// recordCommand stores what a command handed out in the same transaction as
+// the change itself. It fails with errCommandRecorded when the line of the
+// comment was applied before.
+func recordCommand(ctx context.Context, tx pgx.Tx, source commentSource, url string,
+	username string, issuedBy string, bountyLogId pgtype.Int4) error {
+
+	if err := markLineHandled(ctx, tx, source); err != nil {
+		return err
+	}
+	_, err := db.New().AddCommentCommandQuery(ctx, tx, db.AddCommentCommandQueryParams{
+		CommentID:   source.CommentID,
+		Line:        int32(source.Line),
+		Command:     source.Command,
+		IssueUrl:    url,
+		Ghusername:  username,
+		IssuedBy:    issuedBy,
+		BountyLogID: bountyLogId,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errCommandRecorded
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record command: %w", err)
+	}
+	return nil
+}
+
+// markLineHandled records that the command on a line of a comment was handled.
+// It goes in the same transaction as what the command did, or with the
+// rejection, and fails with errCommandRecorded when the line was handled
+// before.
+func markLineHandled(ctx context.Context, tx db.DBTX, source commentSource) error {
+	marked, err := db.New().MarkCommentLineQuery(ctx, tx, db.MarkCommentLineQueryParams{
+		CommentID: source.CommentID,
+		Line:      int32(source.Line),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark comment line: %w", err)
+	}
+	if marked == 0 {
+		return errCommandRecorded
+	}
+	return nil
+}
+
+// awardBadge passes a badge on to Gravemind and records the comment it was
+// given in, so that it can be taken back later
+func awardBadge(ctx context.Context, achievement Achievement, issuedBy string,
+	source commentSource, deliveryId string) error {
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	tx, err := cmd.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = recordCommand(ctx, tx, source, achievement.Url, achievement.ParticipantUsername,
+		issuedBy, pgtype.Int4{})
+	if err != nil {
+		return err
+	}
+	err = queueStream(ctx, tx, pkg.AutomaticEvents, pkg.AchievementEvent, deliveryId, achievement)
+	if err != nil {
+		return fmt.Errorf("failed to queue achievement: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	worker.NotifyOutbox()
+	return nil
+}
+
+// reverseComment takes back everything a deleted comment handed out and
+// returns how many commands were reversed
+func reverseComment(ctx context.Context, commentId int64, reversedBy string,
+	deliveryId string) (int, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	tx, err := cmd.BeginTx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	commands, err := db.New().GetCommentCommandsQuery(ctx, tx, commentId)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch comment commands: %w", err)
+	}
+	for _, command := range commands {
+		err = reverseCommand(ctx, tx, command, reversedBy, CauseCommentDeleted, deliveryId)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	worker.NotifyOutbox()
+	return len(commands), nil
+}
+
+// reverseCommand takes back one recorded command. History is kept, a bounty
+// or penalty is cancelled out by a new entry in bounty_log that points at it.
+func reverseCommand(ctx context.Context, tx pgx.Tx, command db.CommentCommand,
+	reversedBy string, cause string, deliveryId string) error {
+
+	q := db.New()
+	reversal := Reversal{
+		ParticipantUsername: command.Ghusername,
+		Url:                 command.IssueUrl,
+		Action:              strings.ToUpper(strings.TrimPrefix(command.Command, "/")),
+		ReversedBy:          reversedBy,
+		Cause:               cause,
+	}
+
+	if !command.BountyLogID.Valid {
+		// Badges are kept by Gravemind, which takes them back on the event
+		err := queueStream(ctx, tx, pkg.AutomaticEvents, pkg.ReversalEvent, deliveryId, reversal)
+		if err != nil {
+			return fmt.Errorf("failed to queue reversal event: %w", err)
+		}
+		return markReversed(ctx, tx, command)
+	}
+
+	entry, err := q.AddBountyReversalQuery(ctx, tx, db.AddBountyReversalQueryParams{
+		DispatchedBy: reversedBy,
+		ID:           command.BountyLogID.Int32,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add reversal to bounty log: %w", err)
+	}
+	_, err = q.UpdateUserBountyQuery(ctx, tx, db.UpdateUserBountyQueryParams{
+		Bounty:     entry.Amount,
+		Ghusername: pgtype.Text{String: entry.Ghusername, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update user bounty: %w", err)
+	}
+	err = queueLeaderboard(ctx, tx, entry.Ghusername, float64(entry.Amount))
+	if err != nil {
+		return fmt.Errorf("failed to queue leaderboard update: %w", err)
+	}
+
+	reversal.Amount = int(entry.Amount)
+	err = queueStream(ctx, tx, pkg.Bounty, pkg.ReversalEvent, deliveryId, reversal)
+	if err != nil {
+		return fmt.Errorf("failed to queue reversal event: %w", err)
+	}
+	update := newLiveUpdate(LiveBountyReversed, entry.Ghusername, command.IssueUrl)
+	update.Amount = int(entry.Amount)
+	if err = queueLiveUpdate(ctx, tx, deliveryId, update); err != nil {
+		return fmt.Errorf("failed to queue live update: %w", err)
+	}
+	return markReversed(ctx, tx, command)
+}
+
+// undoLastCommand handles /undo. It takes back the latest bounty, penalty or
+// badge the maintainer handed out on the issue within the undo window. The
+// /undo is recorded like any other command so that a redelivered comment
+// does not take back one more.
+func undoLastCommand(ctx context.Context, undo undoRequest, source commentSource,
+	deliveryId string) (string, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	tx, err := cmd.BeginTx(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	window := cmd.AppConfig.UndoWindow
+	command, err := db.New().GetLastCommentCommandQuery(ctx, tx, db.GetLastCommentCommandQueryParams{
+		IssueUrl: undo.Url,
+		IssuedBy: undo.Maintainer,
+		Window:   window.Seconds(),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", &CommandError{
+			Reason: ReasonNothingToUndo,
+			Message: fmt.Sprintf("You have not handed out a bounty, penalty or badge on this issue in the last %s",
+				window),
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch last command: %w", err)
+	}
+
+	err = recordCommand(ctx, tx, source, undo.Url, command.Ghusername, undo.Maintainer, pgtype.Int4{})
+	if err != nil {
+		return "", err
+	}
+	if err = reverseCommand(ctx, tx, command, undo.Maintainer, CauseUndo, deliveryId); err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	worker.NotifyOutbox()
+	return fmt.Sprintf("Reversed %s for %s", command.Command, command.Ghusername), nil
+}
+
+func markReversed(ctx context.Context, tx pgx.Tx, command db.CommentCommand) error {
+	if err := db.New().MarkCommentCommandReversedQuery(ctx, tx, command.ID); err != nil {
+		return fmt.Errorf("failed to mark command reversed: %w", err)
+	}
+	return nil
+}
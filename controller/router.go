@@ -2,12 +2,16 @@ package controller
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"net/http"
 
 	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/IAmRiteshKoushik/alfred/worker"
 	"github.com/gin-gonic/gin"
 	"github.com/google/go-github/v74/github"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestEndpointHandler(c *gin.Context) {
@@ -37,6 +41,118 @@ func WebhookHandler(c *gin.Context) {
 		})
 		return
 	}
+
+	ctx, span := pkg.Tracer.Start(c.Request.Context(), "webhook receive "+eventType,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("github.event", eventType),
+			attribute.String("github.delivery_id", pkg.GrabDeliveryId(c)),
+			attribute.String("alfred.request_id", pkg.GrabRequestId(c)),
+		))
+	defer span.End()
+	c.Request = c.Request.WithContext(ctx)
+
+	// Without an archived copy the delivery would be lost, so GitHub is asked
+	// to redeliver instead.
+	parsedPayload, parseErr := github.ParseWebHook(eventType, payload)
+	pkg.WebhooksReceived.WithLabelValues(eventType, eventAction(parsedPayload)).Inc()
+	addEventLogFields(c, parsedPayload)
+	key, supported := orderingKey(parsedPayload)
+	outcome := worker.Queued
+	switch {
+	case pkg.IsDuplicateDelivery(c):
+		outcome = worker.Duplicate
+	case parseErr != nil || !supported:
+		outcome = worker.Rejected
+	}
+	archiveId, err := archiveDelivery(c, eventType, key, payload, outcome)
+	if err != nil {
+		pkg.Log.Error(c, "Failed to archive webhook delivery", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if outcome == worker.Duplicate {
+		pkg.Log.Info(c, "Skipping duplicate delivery")
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Delivery has already been processed",
+			"duplicate": true,
+		})
+		return
+	}
+
+	if parseErr != nil {
+		pkg.Log.Error(c, "Error parsing request body during webhook event: %v",
+			parseErr,
+		)
+		rejectDelivery(c, archiveId, parseErr)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if !supported {
+		pkg.Log.Warn(c, "Failed to process GitHub Event: "+eventType)
+		rejectDelivery(c, archiveId, fmt.Errorf("unsupported event type %s", eventType))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Unsupported event type",
+		})
+		return
+	}
+
+	// Handlers run on the background workers, GitHub only needs to know
+	// that the delivery is safely stored.
+	worker.NotifyQueue()
+	pkg.Log.Info(c, "Queued "+eventType+" delivery for processing")
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Delivery queued for processing",
+	})
+}
+
+// orderingKey groups deliveries that must be processed one after the other,
+// such as an issue being assigned and then unassigned.
+func orderingKey(payload any) (string, bool) {
+	switch event := payload.(type) {
+	case *github.PingEvent:
+		return event.GetRepo().GetHTMLURL(), true
+	case *github.IssueCommentEvent:
+		return event.GetIssue().GetHTMLURL(), true
+	case *github.IssuesEvent:
+		return event.GetIssue().GetHTMLURL(), true
+	case *github.PullRequestEvent:
+		return event.GetPullRequest().GetHTMLURL(), true
+	}
+	return "", false
+}
+
+// eventAction returns the action of events that have one, such as "opened"
+func eventAction(payload any) string {
+	if event, ok := payload.(interface{ GetAction() string }); ok {
+		return event.GetAction()
+	}
+	return ""
+}
+
+// addEventLogFields attaches the repository, issue or pull request and the
+// user who caused the event to every later log line of the request
+func addEventLogFields(c *gin.Context, payload any) {
+	if event, ok := payload.(interface{ GetRepo() *github.Repository }); ok {
+		pkg.AddLogField(c, pkg.LogRepo, event.GetRepo().GetHTMLURL())
+	}
+	if event, ok := payload.(interface{ GetSender() *github.User }); ok {
+		pkg.AddLogField(c, pkg.LogActor, event.GetSender().GetLogin())
+	}
+	switch event := payload.(type) {
+	case *github.IssueCommentEvent:
+		pkg.AddLogField(c, pkg.LogIssue, event.GetIssue().GetHTMLURL())
+	case *github.IssuesEvent:
+		pkg.AddLogField(c, pkg.LogIssue, event.GetIssue().GetHTMLURL())
+	case *github.PullRequestEvent:
+		pkg.AddLogField(c, pkg.LogIssue, event.GetPullRequest().GetHTMLURL())
+	}
+}
+
+// dispatchEvent parses the payload and routes it to the handler for its
+// event type. It is shared by queued deliveries and replays of archived ones.
+func dispatchEvent(c *gin.Context, eventType string, payload []byte) {
 	parsedPayload, err := github.ParseWebHook(eventType, payload)
 	if err != nil {
 		pkg.Log.Error(c, "Error parsing request body during webhook event: %v",
@@ -46,6 +162,8 @@ func WebhookHandler(c *gin.Context) {
 		return
 	}
 
+	addEventLogFields(c, parsedPayload)
+
 	switch eventType {
 	case "ping":
 		handlePingEvent(c, parsedPayload)
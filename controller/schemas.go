@@ -0,0 +1,23 @@
+package controller
+
+import (
+	"net/http"
+	"path"
+
+	"github.com/IAmRiteshKoushik/alfred/schemas"
+	"github.com/gin-gonic/gin"
+)
+
+// SchemaHandler serves the JSON Schema of a stream event, e.g.
+// /api/schemas/bounty-action.v1.json or /api/schemas/envelope.json
+func SchemaHandler(c *gin.Context) {
+	name := path.Base(c.Param("name"))
+	data, err := schemas.FS.ReadFile(name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "No schema named " + name,
+		})
+		return
+	}
+	c.Data(http.StatusOK, "application/schema+json", data)
+}
@@ -5,6 +5,8 @@
 package db
 
 import (
+	"encoding/json"
+
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
@@ -29,6 +31,48 @@ type BountyLog struct {
 	ProofUrl     string           `json:"proof_url"`
 	Amount       int32            `json:"amount"`
 	CreatedAt    pgtype.Timestamp `json:"created_at"`
+	ReversesID   pgtype.Int4      `json:"reverses_id"`
+}
+
+type ClaimAudit struct {
+	ID          int64            `json:"id"`
+	IssueUrl    string           `json:"issue_url"`
+	Ghusername  string           `json:"ghusername"`
+	Action      string           `json:"action"`
+	PerformedBy string           `json:"performed_by"`
+	CreatedAt   pgtype.Timestamp `json:"created_at"`
+}
+
+type CommentCommand struct {
+	ID          int64            `json:"id"`
+	CommentID   int64            `json:"comment_id"`
+	Line        int32            `json:"line"`
+	Command     string           `json:"command"`
+	IssueUrl    string           `json:"issue_url"`
+	Ghusername  string           `json:"ghusername"`
+	IssuedBy    string           `json:"issued_by"`
+	BountyLogID pgtype.Int4      `json:"bounty_log_id"`
+	ReversedAt  pgtype.Timestamp `json:"reversed_at"`
+	CreatedAt   pgtype.Timestamp `json:"created_at"`
+}
+
+type CommentLine struct {
+	CommentID int64            `json:"comment_id"`
+	Line      int32            `json:"line"`
+	HandledOn pgtype.Timestamp `json:"handled_on"`
+}
+
+type DeadLetter struct {
+	ID         int64            `json:"id"`
+	Kind       string           `json:"kind"`
+	Source     string           `json:"source"`
+	Reference  string           `json:"reference"`
+	Payload    string           `json:"payload"`
+	Error      string           `json:"error"`
+	Attempts   int32            `json:"attempts"`
+	Status     string           `json:"status"`
+	CreatedAt  pgtype.Timestamp `json:"created_at"`
+	ResolvedAt pgtype.Timestamp `json:"resolved_at"`
 }
 
 type Issue struct {
@@ -45,11 +89,22 @@ type Issue struct {
 }
 
 type IssueClaim struct {
-	ID         uuid.UUID        `json:"id"`
-	Ghusername string           `json:"ghusername"`
+	ID                   uuid.UUID        `json:"id"`
+	Ghusername           string           `json:"ghusername"`
+	IssueUrl             string           `json:"issue_url"`
+	ClaimedOn            pgtype.Timestamp `json:"claimed_on"`
+	ElapsedOn            pgtype.Timestamp `json:"elapsed_on"`
+	ExtensionCount       int32            `json:"extension_count"`
+	ExtendedDays         int32            `json:"extended_days"`
+	PendingExtensionDays pgtype.Int4      `json:"pending_extension_days"`
+	RemindedAt           pgtype.Timestamp `json:"reminded_at"`
+}
+
+type IssueWaitlist struct {
+	ID         int64            `json:"id"`
 	IssueUrl   string           `json:"issue_url"`
-	ClaimedOn  pgtype.Timestamp `json:"claimed_on"`
-	ElapsedOn  pgtype.Timestamp `json:"elapsed_on"`
+	Ghusername string           `json:"ghusername"`
+	QueuedOn   pgtype.Timestamp `json:"queued_on"`
 }
 
 type Maintainer struct {
@@ -58,6 +113,27 @@ type Maintainer struct {
 	FullName   string `json:"full_name"`
 }
 
+type OutboxEntry struct {
+	ID          int64            `json:"id"`
+	Command     string           `json:"command"`
+	Key         string           `json:"key"`
+	Value       string           `json:"value"`
+	Increment   float64          `json:"increment"`
+	Attempts    int32            `json:"attempts"`
+	LastError   pgtype.Text      `json:"last_error"`
+	CreatedAt   pgtype.Timestamp `json:"created_at"`
+	PublishedAt pgtype.Timestamp `json:"published_at"`
+	DeadAt      pgtype.Timestamp `json:"dead_at"`
+}
+
+type PendingClaim struct {
+	ID          int64            `json:"id"`
+	IssueUrl    string           `json:"issue_url"`
+	Ghusername  string           `json:"ghusername"`
+	RequestedOn pgtype.Timestamp `json:"requested_on"`
+	ExpiresOn   pgtype.Timestamp `json:"expires_on"`
+}
+
 type Repository struct {
 	ID          uuid.UUID        `json:"id"`
 	Name        string           `json:"name"`
@@ -108,3 +184,19 @@ type UserOnboarding struct {
 	CreatedAt  pgtype.Timestamp `json:"created_at"`
 	ExpiryAt   pgtype.Timestamp `json:"expiry_at"`
 }
+
+type WebhookDelivery struct {
+	ID          uuid.UUID        `json:"id"`
+	DeliveryID  string           `json:"delivery_id"`
+	RequestID   string           `json:"request_id"`
+	EventType   string           `json:"event_type"`
+	Headers     json.RawMessage  `json:"headers"`
+	Payload     []byte           `json:"payload"`
+	Outcome     string           `json:"outcome"`
+	StatusCode  pgtype.Int4      `json:"status_code"`
+	ReceivedAt  pgtype.Timestamp `json:"received_at"`
+	ProcessedAt pgtype.Timestamp `json:"processed_at"`
+	OrderingKey string           `json:"ordering_key"`
+	Attempts    int32            `json:"attempts"`
+	LastError   pgtype.Text      `json:"last_error"`
+}
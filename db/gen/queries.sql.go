@@ -7,13 +7,16 @@ package db
 
 import (
 	"context"
+	"encoding/json"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-const addBountyLogQuery = `-- name: AddBountyLogQuery :exec
+const addBountyLogQuery = `-- name: AddBountyLogQuery :one
 INSERT INTO bounty_log (ghUsername, dispatched_by, proof_url, amount)
 VALUES ($1, $2, $3, $4)
+RETURNING id
 `
 
 type AddBountyLogQueryParams struct {
@@ -23,16 +26,155 @@ type AddBountyLogQueryParams struct {
 	Amount       int32  `json:"amount"`
 }
 
-func (q *Queries) AddBountyLogQuery(ctx context.Context, db DBTX, arg AddBountyLogQueryParams) error {
-	_, err := db.Exec(ctx, addBountyLogQuery,
+func (q *Queries) AddBountyLogQuery(ctx context.Context, db DBTX, arg AddBountyLogQueryParams) (int32, error) {
+	row := db.QueryRow(ctx, addBountyLogQuery,
 		arg.Ghusername,
 		arg.DispatchedBy,
 		arg.ProofUrl,
 		arg.Amount,
 	)
+	var id int32
+	err := row.Scan(&id)
+	return id, err
+}
+
+const addBountyReversalQuery = `-- name: AddBountyReversalQuery :one
+INSERT INTO bounty_log (ghUsername, dispatched_by, proof_url, amount, reverses_id)
+SELECT ghUsername, $1, proof_url, -amount, id
+FROM bounty_log
+WHERE id = $2
+RETURNING id, ghUsername, amount
+`
+
+type AddBountyReversalQueryParams struct {
+	DispatchedBy string `json:"dispatched_by"`
+	ID           int32  `json:"id"`
+}
+
+type AddBountyReversalQueryRow struct {
+	ID         int32  `json:"id"`
+	Ghusername string `json:"ghusername"`
+	Amount     int32  `json:"amount"`
+}
+
+func (q *Queries) AddBountyReversalQuery(ctx context.Context, db DBTX, arg AddBountyReversalQueryParams) (AddBountyReversalQueryRow, error) {
+	row := db.QueryRow(ctx, addBountyReversalQuery, arg.DispatchedBy, arg.ID)
+	var i AddBountyReversalQueryRow
+	err := row.Scan(&i.ID, &i.Ghusername, &i.Amount)
+	return i, err
+}
+
+const addClaimAuditQuery = `-- name: AddClaimAuditQuery :exec
+INSERT INTO claim_audit (issue_url, ghUsername, action, performed_by)
+VALUES ($1, $2, $3, $4)
+`
+
+type AddClaimAuditQueryParams struct {
+	IssueUrl    string `json:"issue_url"`
+	Ghusername  string `json:"ghusername"`
+	Action      string `json:"action"`
+	PerformedBy string `json:"performed_by"`
+}
+
+func (q *Queries) AddClaimAuditQuery(ctx context.Context, db DBTX, arg AddClaimAuditQueryParams) error {
+	_, err := db.Exec(ctx, addClaimAuditQuery,
+		arg.IssueUrl,
+		arg.Ghusername,
+		arg.Action,
+		arg.PerformedBy,
+	)
 	return err
 }
 
+const addCommentCommandQuery = `-- name: AddCommentCommandQuery :one
+INSERT INTO comment_commands (
+    comment_id,
+    line,
+    command,
+    issue_url,
+    ghUsername,
+    issued_by,
+    bounty_log_id
+) VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+ON CONFLICT (comment_id, line) DO NOTHING
+RETURNING id
+`
+
+type AddCommentCommandQueryParams struct {
+	CommentID   int64       `json:"comment_id"`
+	Line        int32       `json:"line"`
+	Command     string      `json:"command"`
+	IssueUrl    string      `json:"issue_url"`
+	Ghusername  string      `json:"ghusername"`
+	IssuedBy    string      `json:"issued_by"`
+	BountyLogID pgtype.Int4 `json:"bounty_log_id"`
+}
+
+func (q *Queries) AddCommentCommandQuery(ctx context.Context, db DBTX, arg AddCommentCommandQueryParams) (int64, error) {
+	row := db.QueryRow(ctx, addCommentCommandQuery,
+		arg.CommentID,
+		arg.Line,
+		arg.Command,
+		arg.IssueUrl,
+		arg.Ghusername,
+		arg.IssuedBy,
+		arg.BountyLogID,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const addDeadLetterQuery = `-- name: AddDeadLetterQuery :one
+INSERT INTO dead_letters (
+    kind,
+    source,
+    reference,
+    payload,
+    error,
+    attempts
+) VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING id
+`
+
+type AddDeadLetterQueryParams struct {
+	Kind      string `json:"kind"`
+	Source    string `json:"source"`
+	Reference string `json:"reference"`
+	Payload   string `json:"payload"`
+	Error     string `json:"error"`
+	Attempts  int32  `json:"attempts"`
+}
+
+func (q *Queries) AddDeadLetterQuery(ctx context.Context, db DBTX, arg AddDeadLetterQueryParams) (int64, error) {
+	row := db.QueryRow(ctx, addDeadLetterQuery,
+		arg.Kind,
+		arg.Source,
+		arg.Reference,
+		arg.Payload,
+		arg.Error,
+		arg.Attempts,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
 const addIssueTagQuery = `-- name: AddIssueTagQuery :one
 UPDATE issues
 SET tags = array_append(tags, $1),
@@ -69,6 +211,59 @@ func (q *Queries) AddNewIssueQuery(ctx context.Context, db DBTX, arg AddNewIssue
 	return err
 }
 
+const addOutboxEntryQuery = `-- name: AddOutboxEntryQuery :exec
+INSERT INTO outbox_entries (command, key, value, increment)
+VALUES ($1, $2, $3, $4)
+`
+
+type AddOutboxEntryQueryParams struct {
+	Command   string  `json:"command"`
+	Key       string  `json:"key"`
+	Value     string  `json:"value"`
+	Increment float64 `json:"increment"`
+}
+
+func (q *Queries) AddOutboxEntryQuery(ctx context.Context, db DBTX, arg AddOutboxEntryQueryParams) error {
+	_, err := db.Exec(ctx, addOutboxEntryQuery,
+		arg.Command,
+		arg.Key,
+		arg.Value,
+		arg.Increment,
+	)
+	return err
+}
+
+const addPendingClaimQuery = `-- name: AddPendingClaimQuery :execrows
+INSERT INTO pending_claims (issue_url, ghUsername, expires_on)
+VALUES (
+    $1,
+    $2,
+    NOW() + make_interval(secs => $3::FLOAT8)
+)
+ON CONFLICT (issue_url) DO UPDATE
+SET
+    ghUsername = EXCLUDED.ghUsername,
+    requested_on = NOW(),
+    expires_on = EXCLUDED.expires_on
+WHERE
+    pending_claims.expires_on <= NOW()
+    OR pending_claims.ghUsername = EXCLUDED.ghUsername
+`
+
+type AddPendingClaimQueryParams struct {
+	IssueUrl   string  `json:"issue_url"`
+	Ghusername string  `json:"ghusername"`
+	Timeout    float64 `json:"timeout"`
+}
+
+func (q *Queries) AddPendingClaimQuery(ctx context.Context, db DBTX, arg AddPendingClaimQueryParams) (int64, error) {
+	result, err := db.Exec(ctx, addPendingClaimQuery, arg.IssueUrl, arg.Ghusername, arg.Timeout)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const addSolutionQuery = `-- name: AddSolutionQuery :one
 INSERT INTO solutions (url, repo_url, ghUsername)
 VALUES ($1, $2, $3)
@@ -88,6 +283,68 @@ func (q *Queries) AddSolutionQuery(ctx context.Context, db DBTX, arg AddSolution
 	return url, err
 }
 
+const addToWaitlistQuery = `-- name: AddToWaitlistQuery :exec
+INSERT INTO issue_waitlist (issue_url, ghUsername)
+VALUES ($1, $2)
+ON CONFLICT (issue_url, ghUsername) DO NOTHING
+`
+
+type AddToWaitlistQueryParams struct {
+	IssueUrl   string `json:"issue_url"`
+	Ghusername string `json:"ghusername"`
+}
+
+func (q *Queries) AddToWaitlistQuery(ctx context.Context, db DBTX, arg AddToWaitlistQueryParams) error {
+	_, err := db.Exec(ctx, addToWaitlistQuery, arg.IssueUrl, arg.Ghusername)
+	return err
+}
+
+const addWebhookDeliveryQuery = `-- name: AddWebhookDeliveryQuery :one
+INSERT INTO webhook_deliveries (
+    delivery_id,
+    request_id,
+    event_type,
+    ordering_key,
+    headers,
+    payload,
+    outcome
+) VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING id
+`
+
+type AddWebhookDeliveryQueryParams struct {
+	DeliveryID  string          `json:"delivery_id"`
+	RequestID   string          `json:"request_id"`
+	EventType   string          `json:"event_type"`
+	OrderingKey string          `json:"ordering_key"`
+	Headers     json.RawMessage `json:"headers"`
+	Payload     []byte          `json:"payload"`
+	Outcome     string          `json:"outcome"`
+}
+
+func (q *Queries) AddWebhookDeliveryQuery(ctx context.Context, db DBTX, arg AddWebhookDeliveryQueryParams) (uuid.UUID, error) {
+	row := db.QueryRow(ctx, addWebhookDeliveryQuery,
+		arg.DeliveryID,
+		arg.RequestID,
+		arg.EventType,
+		arg.OrderingKey,
+		arg.Headers,
+		arg.Payload,
+		arg.Outcome,
+	)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
 const checkIfSolutionExist = `-- name: CheckIfSolutionExist :one
 SELECT
     id
@@ -139,6 +396,76 @@ func (q *Queries) CheckOpenIssueQuery(ctx context.Context, db DBTX, url string)
 	return found, err
 }
 
+const claimQueuedDeliveriesQuery = `-- name: ClaimQueuedDeliveriesQuery :many
+UPDATE webhook_deliveries
+SET outcome = 'processing'
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE outcome = 'queued'
+    ORDER BY received_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, delivery_id, request_id, event_type, headers, payload, outcome, status_code, received_at, processed_at, ordering_key, attempts, last_error
+`
+
+func (q *Queries) ClaimQueuedDeliveriesQuery(ctx context.Context, db DBTX, limit int32) ([]WebhookDelivery, error) {
+	rows, err := db.Query(ctx, claimQueuedDeliveriesQuery, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.DeliveryID,
+			&i.RequestID,
+			&i.EventType,
+			&i.Headers,
+			&i.Payload,
+			&i.Outcome,
+			&i.StatusCode,
+			&i.ReceivedAt,
+			&i.ProcessedAt,
+			&i.OrderingKey,
+			&i.Attempts,
+			&i.LastError,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const clearPendingClaimQuery = `-- name: ClearPendingClaimQuery :exec
+DELETE FROM pending_claims
+WHERE issue_url = $1
+`
+
+func (q *Queries) ClearPendingClaimQuery(ctx context.Context, db DBTX, issueUrl string) error {
+	_, err := db.Exec(ctx, clearPendingClaimQuery, issueUrl)
+	return err
+}
+
+const clearWaitlistQuery = `-- name: ClearWaitlistQuery :execrows
+DELETE FROM issue_waitlist
+WHERE issue_url = $1
+`
+
+func (q *Queries) ClearWaitlistQuery(ctx context.Context, db DBTX, issueUrl string) (int64, error) {
+	result, err := db.Exec(ctx, clearWaitlistQuery, issueUrl)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const closeIssueQuery = `-- name: CloseIssueQuery :one
 UPDATE issues
 SET
@@ -155,6 +482,86 @@ func (q *Queries) CloseIssueQuery(ctx context.Context, db DBTX, url string) (str
 	return url, err
 }
 
+const countActiveClaimsQuery = `-- name: CountActiveClaimsQuery :many
+SELECT
+    UPPER(COALESCE(i.difficulty, 'EASY'))::TEXT AS difficulty,
+    COUNT(*) AS claims
+FROM (
+    SELECT issue_url FROM issue_claims
+    WHERE ghUsername = $1 AND elapsed_on > NOW()
+    UNION
+    SELECT issue_url FROM pending_claims
+    WHERE ghUsername = $1 AND expires_on > NOW()
+) c
+LEFT JOIN issues i ON i.url = c.issue_url
+GROUP BY 1
+`
+
+type CountActiveClaimsQueryRow struct {
+	Difficulty string `json:"difficulty"`
+	Claims     int64  `json:"claims"`
+}
+
+func (q *Queries) CountActiveClaimsQuery(ctx context.Context, db DBTX, ghusername string) ([]CountActiveClaimsQueryRow, error) {
+	rows, err := db.Query(ctx, countActiveClaimsQuery, ghusername)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountActiveClaimsQueryRow
+	for rows.Next() {
+		var i CountActiveClaimsQueryRow
+		if err := rows.Scan(&i.Difficulty, &i.Claims); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countWaitlistQuery = `-- name: CountWaitlistQuery :one
+SELECT COUNT(*) FROM issue_waitlist
+WHERE issue_url = $1
+`
+
+func (q *Queries) CountWaitlistQuery(ctx context.Context, db DBTX, issueUrl string) (int64, error) {
+	row := db.QueryRow(ctx, countWaitlistQuery, issueUrl)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteClaimQuery = `-- name: DeleteClaimQuery :exec
+DELETE FROM issue_claims
+WHERE id = $1
+`
+
+func (q *Queries) DeleteClaimQuery(ctx context.Context, db DBTX, id uuid.UUID) error {
+	_, err := db.Exec(ctx, deleteClaimQuery, id)
+	return err
+}
+
+const deletePendingClaimQuery = `-- name: DeletePendingClaimQuery :execrows
+DELETE FROM pending_claims
+WHERE issue_url = $1 AND ghUsername = $2
+`
+
+type DeletePendingClaimQueryParams struct {
+	IssueUrl   string `json:"issue_url"`
+	Ghusername string `json:"ghusername"`
+}
+
+func (q *Queries) DeletePendingClaimQuery(ctx context.Context, db DBTX, arg DeletePendingClaimQueryParams) (int64, error) {
+	result, err := db.Exec(ctx, deletePendingClaimQuery, arg.IssueUrl, arg.Ghusername)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const deleteSolutionQuery = `-- name: DeleteSolutionQuery :one
 DELETE FROM solutions
 WHERE url = $1
@@ -167,30 +574,368 @@ func (q *Queries) DeleteSolutionQuery(ctx context.Context, db DBTX, url string)
 	return url, err
 }
 
+const deleteWaitlistEntryQuery = `-- name: DeleteWaitlistEntryQuery :exec
+DELETE FROM issue_waitlist
+WHERE id = $1
+`
+
+func (q *Queries) DeleteWaitlistEntryQuery(ctx context.Context, db DBTX, id int64) error {
+	_, err := db.Exec(ctx, deleteWaitlistEntryQuery, id)
+	return err
+}
+
 const extendClaimQuery = `-- name: ExtendClaimQuery :one
 UPDATE issue_claims
 SET
-    elapsed_on = elapsed_on + make_interval(days => $1)
+    elapsed_on = elapsed_on + make_interval(days => $1::INTEGER),
+    extension_count = extension_count + 1,
+    extended_days = extended_days + $1::INTEGER,
+    pending_extension_days = NULL,
+    reminded_at = NULL
 WHERE
-    ghUsername = $2 
-    AND issue_url = $3
-    AND elapsed_on > NOW()
-returning ghUsername
+    id = $2
+RETURNING elapsed_on
 `
 
 type ExtendClaimQueryParams struct {
-	Days       int32  `json:"days"`
+	Days int32     `json:"days"`
+	ID   uuid.UUID `json:"id"`
+}
+
+func (q *Queries) ExtendClaimQuery(ctx context.Context, db DBTX, arg ExtendClaimQueryParams) (pgtype.Timestamp, error) {
+	row := db.QueryRow(ctx, extendClaimQuery, arg.Days, arg.ID)
+	var elapsed_on pgtype.Timestamp
+	err := row.Scan(&elapsed_on)
+	return elapsed_on, err
+}
+
+const getActiveClaimQuery = `-- name: GetActiveClaimQuery :one
+SELECT id, ghusername, issue_url, claimed_on, elapsed_on, extension_count, extended_days, pending_extension_days, reminded_at FROM issue_claims
+WHERE
+    ghUsername = $1
+    AND issue_url = $2
+    AND elapsed_on > NOW()
+FOR UPDATE
+`
+
+type GetActiveClaimQueryParams struct {
 	Ghusername string `json:"ghusername"`
 	IssueUrl   string `json:"issue_url"`
 }
 
-func (q *Queries) ExtendClaimQuery(ctx context.Context, db DBTX, arg ExtendClaimQueryParams) (string, error) {
-	row := db.QueryRow(ctx, extendClaimQuery, arg.Days, arg.Ghusername, arg.IssueUrl)
+func (q *Queries) GetActiveClaimQuery(ctx context.Context, db DBTX, arg GetActiveClaimQueryParams) (IssueClaim, error) {
+	row := db.QueryRow(ctx, getActiveClaimQuery, arg.Ghusername, arg.IssueUrl)
+	var i IssueClaim
+	err := row.Scan(
+		&i.ID,
+		&i.Ghusername,
+		&i.IssueUrl,
+		&i.ClaimedOn,
+		&i.ElapsedOn,
+		&i.ExtensionCount,
+		&i.ExtendedDays,
+		&i.PendingExtensionDays,
+		&i.RemindedAt,
+	)
+	return i, err
+}
+
+const getClaimableIssueQuery = `-- name: GetClaimableIssueQuery :one
+SELECT
+    i.difficulty,
+    COALESCE(i.resolved, FALSE)::BOOLEAN AS resolved,
+    r.on_display
+FROM issues i
+JOIN repository r ON r.url = i.repoUrl
+WHERE i.url = $1
+`
+
+type GetClaimableIssueQueryRow struct {
+	Difficulty string `json:"difficulty"`
+	Resolved   bool   `json:"resolved"`
+	OnDisplay  bool   `json:"on_display"`
+}
+
+func (q *Queries) GetClaimableIssueQuery(ctx context.Context, db DBTX, url string) (GetClaimableIssueQueryRow, error) {
+	row := db.QueryRow(ctx, getClaimableIssueQuery, url)
+	var i GetClaimableIssueQueryRow
+	err := row.Scan(&i.Difficulty, &i.Resolved, &i.OnDisplay)
+	return i, err
+}
+
+const getClaimsDueReminderQuery = `-- name: GetClaimsDueReminderQuery :many
+SELECT id, ghusername, issue_url, claimed_on, elapsed_on, extension_count, extended_days, pending_extension_days, reminded_at FROM issue_claims
+WHERE
+    reminded_at IS NULL
+    AND elapsed_on > NOW()
+    AND elapsed_on <= NOW() + make_interval(secs => $1::FLOAT8)
+ORDER BY elapsed_on
+LIMIT $2
+FOR UPDATE SKIP LOCKED
+`
+
+type GetClaimsDueReminderQueryParams struct {
+	RemindBefore float64 `json:"remind_before"`
+	BatchSize    int32   `json:"batch_size"`
+}
+
+func (q *Queries) GetClaimsDueReminderQuery(ctx context.Context, db DBTX, arg GetClaimsDueReminderQueryParams) ([]IssueClaim, error) {
+	rows, err := db.Query(ctx, getClaimsDueReminderQuery, arg.RemindBefore, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []IssueClaim
+	for rows.Next() {
+		var i IssueClaim
+		if err := rows.Scan(
+			&i.ID,
+			&i.Ghusername,
+			&i.IssueUrl,
+			&i.ClaimedOn,
+			&i.ElapsedOn,
+			&i.ExtensionCount,
+			&i.ExtendedDays,
+			&i.PendingExtensionDays,
+			&i.RemindedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCommentCommandsQuery = `-- name: GetCommentCommandsQuery :many
+SELECT id, comment_id, line, command, issue_url, ghusername, issued_by, bounty_log_id, reversed_at, created_at FROM comment_commands
+WHERE comment_id = $1 AND reversed_at IS NULL AND command <> '/undo'
+ORDER BY line
+FOR UPDATE
+`
+
+func (q *Queries) GetCommentCommandsQuery(ctx context.Context, db DBTX, commentID int64) ([]CommentCommand, error) {
+	rows, err := db.Query(ctx, getCommentCommandsQuery, commentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CommentCommand
+	for rows.Next() {
+		var i CommentCommand
+		if err := rows.Scan(
+			&i.ID,
+			&i.CommentID,
+			&i.Line,
+			&i.Command,
+			&i.IssueUrl,
+			&i.Ghusername,
+			&i.IssuedBy,
+			&i.BountyLogID,
+			&i.ReversedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCommentLinesQuery = `-- name: GetCommentLinesQuery :many
+SELECT line FROM comment_lines
+WHERE comment_id = $1
+ORDER BY line
+`
+
+func (q *Queries) GetCommentLinesQuery(ctx context.Context, db DBTX, commentID int64) ([]int32, error) {
+	rows, err := db.Query(ctx, getCommentLinesQuery, commentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var line int32
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		items = append(items, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDeadLetterQuery = `-- name: GetDeadLetterQuery :one
+SELECT id, kind, source, reference, payload, error, attempts, status, created_at, resolved_at FROM dead_letters
+WHERE id = $1
+`
+
+func (q *Queries) GetDeadLetterQuery(ctx context.Context, db DBTX, id int64) (DeadLetter, error) {
+	row := db.QueryRow(ctx, getDeadLetterQuery, id)
+	var i DeadLetter
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.Source,
+		&i.Reference,
+		&i.Payload,
+		&i.Error,
+		&i.Attempts,
+		&i.Status,
+		&i.CreatedAt,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const getExpiredClaimsQuery = `-- name: GetExpiredClaimsQuery :many
+SELECT id, ghusername, issue_url, claimed_on, elapsed_on, extension_count, extended_days, pending_extension_days, reminded_at FROM issue_claims
+WHERE elapsed_on <= NOW()
+ORDER BY elapsed_on
+LIMIT $1
+FOR UPDATE SKIP LOCKED
+`
+
+func (q *Queries) GetExpiredClaimsQuery(ctx context.Context, db DBTX, limit int32) ([]IssueClaim, error) {
+	rows, err := db.Query(ctx, getExpiredClaimsQuery, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []IssueClaim
+	for rows.Next() {
+		var i IssueClaim
+		if err := rows.Scan(
+			&i.ID,
+			&i.Ghusername,
+			&i.IssueUrl,
+			&i.ClaimedOn,
+			&i.ElapsedOn,
+			&i.ExtensionCount,
+			&i.ExtendedDays,
+			&i.PendingExtensionDays,
+			&i.RemindedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getExpiredPendingClaimsQuery = `-- name: GetExpiredPendingClaimsQuery :many
+SELECT id, issue_url, ghusername, requested_on, expires_on FROM pending_claims
+WHERE expires_on <= NOW()
+ORDER BY expires_on
+LIMIT $1
+FOR UPDATE SKIP LOCKED
+`
+
+func (q *Queries) GetExpiredPendingClaimsQuery(ctx context.Context, db DBTX, limit int32) ([]PendingClaim, error) {
+	rows, err := db.Query(ctx, getExpiredPendingClaimsQuery, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PendingClaim
+	for rows.Next() {
+		var i PendingClaim
+		if err := rows.Scan(
+			&i.ID,
+			&i.IssueUrl,
+			&i.Ghusername,
+			&i.RequestedOn,
+			&i.ExpiresOn,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getIssueClaimantQuery = `-- name: GetIssueClaimantQuery :one
+SELECT ghUsername FROM (
+    SELECT ghUsername, claimed_on AS since FROM issue_claims
+    WHERE issue_url = $1 AND elapsed_on > NOW()
+    UNION ALL
+    SELECT ghUsername, requested_on AS since FROM pending_claims
+    WHERE issue_url = $1 AND expires_on > NOW()
+) c
+ORDER BY since
+LIMIT 1
+`
+
+func (q *Queries) GetIssueClaimantQuery(ctx context.Context, db DBTX, issueUrl string) (string, error) {
+	row := db.QueryRow(ctx, getIssueClaimantQuery, issueUrl)
 	var ghusername string
 	err := row.Scan(&ghusername)
 	return ghusername, err
 }
 
+const getIssueDifficultyQuery = `-- name: GetIssueDifficultyQuery :one
+SELECT difficulty FROM issues
+WHERE url = $1
+`
+
+func (q *Queries) GetIssueDifficultyQuery(ctx context.Context, db DBTX, url string) (string, error) {
+	row := db.QueryRow(ctx, getIssueDifficultyQuery, url)
+	var difficulty string
+	err := row.Scan(&difficulty)
+	return difficulty, err
+}
+
+const getLastCommentCommandQuery = `-- name: GetLastCommentCommandQuery :one
+SELECT id, comment_id, line, command, issue_url, ghusername, issued_by, bounty_log_id, reversed_at, created_at FROM comment_commands
+WHERE
+    issue_url = $1
+    AND issued_by = $2
+    AND reversed_at IS NULL
+    AND command <> '/undo'
+    AND created_at > NOW() - make_interval(secs => $3::FLOAT8)
+ORDER BY created_at DESC, id DESC
+LIMIT 1
+FOR UPDATE
+`
+
+type GetLastCommentCommandQueryParams struct {
+	IssueUrl string  `json:"issue_url"`
+	IssuedBy string  `json:"issued_by"`
+	Window   float64 `json:"window"`
+}
+
+func (q *Queries) GetLastCommentCommandQuery(ctx context.Context, db DBTX, arg GetLastCommentCommandQueryParams) (CommentCommand, error) {
+	row := db.QueryRow(ctx, getLastCommentCommandQuery, arg.IssueUrl, arg.IssuedBy, arg.Window)
+	var i CommentCommand
+	err := row.Scan(
+		&i.ID,
+		&i.CommentID,
+		&i.Line,
+		&i.Command,
+		&i.IssueUrl,
+		&i.Ghusername,
+		&i.IssuedBy,
+		&i.BountyLogID,
+		&i.ReversedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
 const getMaintainersQuery = `-- name: GetMaintainersQuery :one
 SELECT maintainers FROM repository
 WHERE url = $1
@@ -203,17 +948,225 @@ func (q *Queries) GetMaintainersQuery(ctx context.Context, db DBTX, url string)
 	return maintainers, err
 }
 
-const issueAssignQuery = `-- name: IssueAssignQuery :exec
+const getOutboxLagQuery = `-- name: GetOutboxLagQuery :one
+SELECT
+    COUNT(*) AS pending,
+    COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(created_at)), 0)::FLOAT8 AS oldest_age
+FROM outbox_entries
+WHERE published_at IS NULL AND dead_at IS NULL
+`
+
+type GetOutboxLagQueryRow struct {
+	Pending   int64   `json:"pending"`
+	OldestAge float64 `json:"oldest_age"`
+}
+
+func (q *Queries) GetOutboxLagQuery(ctx context.Context, db DBTX) (GetOutboxLagQueryRow, error) {
+	row := db.QueryRow(ctx, getOutboxLagQuery)
+	var i GetOutboxLagQueryRow
+	err := row.Scan(&i.Pending, &i.OldestAge)
+	return i, err
+}
+
+const getPendingOutboxEntriesQuery = `-- name: GetPendingOutboxEntriesQuery :many
+SELECT id, command, key, value, increment, attempts, last_error, created_at, published_at, dead_at FROM outbox_entries
+WHERE published_at IS NULL AND dead_at IS NULL
+ORDER BY id
+LIMIT $1
+FOR UPDATE SKIP LOCKED
+`
+
+func (q *Queries) GetPendingOutboxEntriesQuery(ctx context.Context, db DBTX, limit int32) ([]OutboxEntry, error) {
+	rows, err := db.Query(ctx, getPendingOutboxEntriesQuery, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OutboxEntry
+	for rows.Next() {
+		var i OutboxEntry
+		if err := rows.Scan(
+			&i.ID,
+			&i.Command,
+			&i.Key,
+			&i.Value,
+			&i.Increment,
+			&i.Attempts,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.PublishedAt,
+			&i.DeadAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getQueueLagQuery = `-- name: GetQueueLagQuery :one
+SELECT
+    COUNT(*) AS pending,
+    COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(received_at)), 0)::FLOAT8 AS oldest_age
+FROM webhook_deliveries
+WHERE outcome IN ('queued', 'processing')
+`
+
+type GetQueueLagQueryRow struct {
+	Pending   int64   `json:"pending"`
+	OldestAge float64 `json:"oldest_age"`
+}
+
+func (q *Queries) GetQueueLagQuery(ctx context.Context, db DBTX) (GetQueueLagQueryRow, error) {
+	row := db.QueryRow(ctx, getQueueLagQuery)
+	var i GetQueueLagQueryRow
+	err := row.Scan(&i.Pending, &i.OldestAge)
+	return i, err
+}
+
+const getWaitlistPositionQuery = `-- name: GetWaitlistPositionQuery :one
+SELECT COUNT(*) FROM issue_waitlist
+WHERE
+    issue_url = $1
+    AND id <= (
+        SELECT id FROM issue_waitlist
+        WHERE issue_url = $1 AND ghUsername = $2
+    )
+`
+
+type GetWaitlistPositionQueryParams struct {
+	IssueUrl   string `json:"issue_url"`
+	Ghusername string `json:"ghusername"`
+}
+
+func (q *Queries) GetWaitlistPositionQuery(ctx context.Context, db DBTX, arg GetWaitlistPositionQueryParams) (int64, error) {
+	row := db.QueryRow(ctx, getWaitlistPositionQuery, arg.IssueUrl, arg.Ghusername)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const getWaitlistQuery = `-- name: GetWaitlistQuery :many
+SELECT id, issue_url, ghusername, queued_on FROM issue_waitlist
+WHERE issue_url = $1
+ORDER BY id
+FOR UPDATE
+`
+
+func (q *Queries) GetWaitlistQuery(ctx context.Context, db DBTX, issueUrl string) ([]IssueWaitlist, error) {
+	rows, err := db.Query(ctx, getWaitlistQuery, issueUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []IssueWaitlist
+	for rows.Next() {
+		var i IssueWaitlist
+		if err := rows.Scan(
+			&i.ID,
+			&i.IssueUrl,
+			&i.Ghusername,
+			&i.QueuedOn,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhookDeliveriesInRangeQuery = `-- name: GetWebhookDeliveriesInRangeQuery :many
+SELECT id, delivery_id, request_id, event_type, headers, payload, outcome, status_code, received_at, processed_at, ordering_key, attempts, last_error FROM webhook_deliveries
+WHERE
+    received_at >= $1
+    AND received_at < $2
+ORDER BY received_at
+`
+
+type GetWebhookDeliveriesInRangeQueryParams struct {
+	FromTime pgtype.Timestamp `json:"from_time"`
+	ToTime   pgtype.Timestamp `json:"to_time"`
+}
+
+func (q *Queries) GetWebhookDeliveriesInRangeQuery(ctx context.Context, db DBTX, arg GetWebhookDeliveriesInRangeQueryParams) ([]WebhookDelivery, error) {
+	rows, err := db.Query(ctx, getWebhookDeliveriesInRangeQuery, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.DeliveryID,
+			&i.RequestID,
+			&i.EventType,
+			&i.Headers,
+			&i.Payload,
+			&i.Outcome,
+			&i.StatusCode,
+			&i.ReceivedAt,
+			&i.ProcessedAt,
+			&i.OrderingKey,
+			&i.Attempts,
+			&i.LastError,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhookDeliveryQuery = `-- name: GetWebhookDeliveryQuery :one
+SELECT id, delivery_id, request_id, event_type, headers, payload, outcome, status_code, received_at, processed_at, ordering_key, attempts, last_error FROM webhook_deliveries
+WHERE delivery_id = $1
+ORDER BY received_at DESC
+LIMIT 1
+`
+
+func (q *Queries) GetWebhookDeliveryQuery(ctx context.Context, db DBTX, deliveryID string) (WebhookDelivery, error) {
+	row := db.QueryRow(ctx, getWebhookDeliveryQuery, deliveryID)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.DeliveryID,
+		&i.RequestID,
+		&i.EventType,
+		&i.Headers,
+		&i.Payload,
+		&i.Outcome,
+		&i.StatusCode,
+		&i.ReceivedAt,
+		&i.ProcessedAt,
+		&i.OrderingKey,
+		&i.Attempts,
+		&i.LastError,
+	)
+	return i, err
+}
+
+const issueAssignQuery = `-- name: IssueAssignQuery :execrows
 INSERT INTO issue_claims (
     ghUsername,
     issue_url,
     claimed_on,
     elapsed_on
-) VALUES (
-    $1,
-    $2,
-    $3,
-    $4
+)
+SELECT $1, $2, $3, $4
+WHERE NOT EXISTS (
+    SELECT 1 FROM issue_claims
+    WHERE issue_url = $2 AND elapsed_on > NOW()
 )
 `
 
@@ -224,14 +1177,17 @@ type IssueAssignQueryParams struct {
 	ElapsedOn  pgtype.Timestamp `json:"elapsed_on"`
 }
 
-func (q *Queries) IssueAssignQuery(ctx context.Context, db DBTX, arg IssueAssignQueryParams) error {
-	_, err := db.Exec(ctx, issueAssignQuery,
+func (q *Queries) IssueAssignQuery(ctx context.Context, db DBTX, arg IssueAssignQueryParams) (int64, error) {
+	result, err := db.Exec(ctx, issueAssignQuery,
 		arg.Ghusername,
 		arg.IssueUrl,
 		arg.ClaimedOn,
 		arg.ElapsedOn,
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
 const issueUnassignQuery = `-- name: IssueUnassignQuery :one
@@ -253,6 +1209,172 @@ func (q *Queries) IssueUnassignQuery(ctx context.Context, db DBTX, arg IssueUnas
 	return ghusername, err
 }
 
+const leaveWaitlistQuery = `-- name: LeaveWaitlistQuery :execrows
+DELETE FROM issue_waitlist
+WHERE issue_url = $1 AND ghUsername = $2
+`
+
+type LeaveWaitlistQueryParams struct {
+	IssueUrl   string `json:"issue_url"`
+	Ghusername string `json:"ghusername"`
+}
+
+func (q *Queries) LeaveWaitlistQuery(ctx context.Context, db DBTX, arg LeaveWaitlistQueryParams) (int64, error) {
+	result, err := db.Exec(ctx, leaveWaitlistQuery, arg.IssueUrl, arg.Ghusername)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const listDeadLettersQuery = `-- name: ListDeadLettersQuery :many
+SELECT id, kind, source, reference, payload, error, attempts, status, created_at, resolved_at FROM dead_letters
+WHERE status = $1
+ORDER BY id
+`
+
+func (q *Queries) ListDeadLettersQuery(ctx context.Context, db DBTX, status string) ([]DeadLetter, error) {
+	rows, err := db.Query(ctx, listDeadLettersQuery, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DeadLetter
+	for rows.Next() {
+		var i DeadLetter
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.Source,
+			&i.Reference,
+			&i.Payload,
+			&i.Error,
+			&i.Attempts,
+			&i.Status,
+			&i.CreatedAt,
+			&i.ResolvedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockIssueClaimQuery = `-- name: LockIssueClaimQuery :exec
+SELECT pg_advisory_xact_lock(hashtext($1::TEXT))
+`
+
+func (q *Queries) LockIssueClaimQuery(ctx context.Context, db DBTX, issueUrl string) error {
+	_, err := db.Exec(ctx, lockIssueClaimQuery, issueUrl)
+	return err
+}
+
+const lockParticipantClaimsQuery = `-- name: LockParticipantClaimsQuery :exec
+SELECT pg_advisory_xact_lock(hashtext($1::TEXT))
+`
+
+func (q *Queries) LockParticipantClaimsQuery(ctx context.Context, db DBTX, ghusername string) error {
+	_, err := db.Exec(ctx, lockParticipantClaimsQuery, ghusername)
+	return err
+}
+
+const markClaimRemindedQuery = `-- name: MarkClaimRemindedQuery :exec
+UPDATE issue_claims
+SET reminded_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) MarkClaimRemindedQuery(ctx context.Context, db DBTX, id uuid.UUID) error {
+	_, err := db.Exec(ctx, markClaimRemindedQuery, id)
+	return err
+}
+
+const markCommentCommandReversedQuery = `-- name: MarkCommentCommandReversedQuery :exec
+UPDATE comment_commands
+SET
+    reversed_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) MarkCommentCommandReversedQuery(ctx context.Context, db DBTX, id int64) error {
+	_, err := db.Exec(ctx, markCommentCommandReversedQuery, id)
+	return err
+}
+
+const markCommentLineQuery = `-- name: MarkCommentLineQuery :execrows
+INSERT INTO comment_lines (comment_id, line)
+VALUES ($1, $2)
+ON CONFLICT (comment_id, line) DO NOTHING
+`
+
+type MarkCommentLineQueryParams struct {
+	CommentID int64 `json:"comment_id"`
+	Line      int32 `json:"line"`
+}
+
+func (q *Queries) MarkCommentLineQuery(ctx context.Context, db DBTX, arg MarkCommentLineQueryParams) (int64, error) {
+	result, err := db.Exec(ctx, markCommentLineQuery, arg.CommentID, arg.Line)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const markOutboxEntryDeadQuery = `-- name: MarkOutboxEntryDeadQuery :exec
+UPDATE outbox_entries
+SET
+    attempts = attempts + 1,
+    last_error = $1,
+    dead_at = NOW()
+WHERE id = $2
+`
+
+type MarkOutboxEntryDeadQueryParams struct {
+	LastError pgtype.Text `json:"last_error"`
+	ID        int64       `json:"id"`
+}
+
+func (q *Queries) MarkOutboxEntryDeadQuery(ctx context.Context, db DBTX, arg MarkOutboxEntryDeadQueryParams) error {
+	_, err := db.Exec(ctx, markOutboxEntryDeadQuery, arg.LastError, arg.ID)
+	return err
+}
+
+const markOutboxEntryFailedQuery = `-- name: MarkOutboxEntryFailedQuery :exec
+UPDATE outbox_entries
+SET
+    attempts = attempts + 1,
+    last_error = $1
+WHERE id = $2
+`
+
+type MarkOutboxEntryFailedQueryParams struct {
+	LastError pgtype.Text `json:"last_error"`
+	ID        int64       `json:"id"`
+}
+
+func (q *Queries) MarkOutboxEntryFailedQuery(ctx context.Context, db DBTX, arg MarkOutboxEntryFailedQueryParams) error {
+	_, err := db.Exec(ctx, markOutboxEntryFailedQuery, arg.LastError, arg.ID)
+	return err
+}
+
+const markOutboxEntryPublishedQuery = `-- name: MarkOutboxEntryPublishedQuery :exec
+UPDATE outbox_entries
+SET
+    attempts = attempts + 1,
+    last_error = NULL,
+    published_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) MarkOutboxEntryPublishedQuery(ctx context.Context, db DBTX, id int64) error {
+	_, err := db.Exec(ctx, markOutboxEntryPublishedQuery, id)
+	return err
+}
+
 const mergeSolutionQuery = `-- name: MergeSolutionQuery :one
 UPDATE solutions
 SET
@@ -300,6 +1422,114 @@ func (q *Queries) ParticipantExistsQuery(ctx context.Context, db DBTX, ghusernam
 	return found, err
 }
 
+const requestClaimExtensionQuery = `-- name: RequestClaimExtensionQuery :exec
+UPDATE issue_claims
+SET
+    pending_extension_days = $1
+WHERE
+    id = $2
+`
+
+type RequestClaimExtensionQueryParams struct {
+	PendingExtensionDays pgtype.Int4 `json:"pending_extension_days"`
+	ID                   uuid.UUID   `json:"id"`
+}
+
+func (q *Queries) RequestClaimExtensionQuery(ctx context.Context, db DBTX, arg RequestClaimExtensionQueryParams) error {
+	_, err := db.Exec(ctx, requestClaimExtensionQuery, arg.PendingExtensionDays, arg.ID)
+	return err
+}
+
+const requeueProcessingDeliveriesQuery = `-- name: RequeueProcessingDeliveriesQuery :execrows
+UPDATE webhook_deliveries
+SET outcome = 'queued'
+WHERE outcome = 'processing'
+`
+
+func (q *Queries) RequeueProcessingDeliveriesQuery(ctx context.Context, db DBTX) (int64, error) {
+	result, err := db.Exec(ctx, requeueProcessingDeliveriesQuery)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const requeueWebhookDeliveryQuery = `-- name: RequeueWebhookDeliveryQuery :execrows
+UPDATE webhook_deliveries
+SET
+    outcome = 'queued',
+    attempts = 0
+WHERE id = $1 AND outcome = 'failed'
+`
+
+func (q *Queries) RequeueWebhookDeliveryQuery(ctx context.Context, db DBTX, id uuid.UUID) (int64, error) {
+	result, err := db.Exec(ctx, requeueWebhookDeliveryQuery, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const rescheduleClaimsQuery = `-- name: RescheduleClaimsQuery :execrows
+UPDATE issue_claims
+SET
+    elapsed_on = claimed_on + make_interval(days => $1::INTEGER + extended_days),
+    reminded_at = NULL
+WHERE
+    issue_url = $2
+    AND elapsed_on > NOW()
+`
+
+type RescheduleClaimsQueryParams struct {
+	Days     int32  `json:"days"`
+	IssueUrl string `json:"issue_url"`
+}
+
+func (q *Queries) RescheduleClaimsQuery(ctx context.Context, db DBTX, arg RescheduleClaimsQueryParams) (int64, error) {
+	result, err := db.Exec(ctx, rescheduleClaimsQuery, arg.Days, arg.IssueUrl)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const resolveDeadLetterQuery = `-- name: ResolveDeadLetterQuery :one
+UPDATE dead_letters
+SET
+    status = $1,
+    resolved_at = NOW()
+WHERE id = $2 AND status = 'open'
+RETURNING id
+`
+
+type ResolveDeadLetterQueryParams struct {
+	Status string `json:"status"`
+	ID     int64  `json:"id"`
+}
+
+func (q *Queries) ResolveDeadLetterQuery(ctx context.Context, db DBTX, arg ResolveDeadLetterQueryParams) (int64, error) {
+	row := db.QueryRow(ctx, resolveDeadLetterQuery, arg.Status, arg.ID)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const reviveOutboxEntryQuery = `-- name: ReviveOutboxEntryQuery :execrows
+UPDATE outbox_entries
+SET
+    attempts = 0,
+    dead_at = NULL
+WHERE id = $1 AND dead_at IS NOT NULL
+`
+
+func (q *Queries) ReviveOutboxEntryQuery(ctx context.Context, db DBTX, id int64) (int64, error) {
+	result, err := db.Exec(ctx, reviveOutboxEntryQuery, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateIssueBountyQuery = `-- name: UpdateIssueBountyQuery :one
 UPDATE issues
 SET
@@ -374,6 +1604,36 @@ func (q *Queries) UpdateUserBountyQuery(ctx context.Context, db DBTX, arg Update
 	return bounty, err
 }
 
+const updateWebhookDeliveryOutcomeQuery = `-- name: UpdateWebhookDeliveryOutcomeQuery :exec
+UPDATE webhook_deliveries
+SET
+    outcome = $1,
+    status_code = $2,
+    attempts = $3,
+    last_error = $4,
+    processed_at = NOW()
+WHERE id = $5
+`
+
+type UpdateWebhookDeliveryOutcomeQueryParams struct {
+	Outcome    string      `json:"outcome"`
+	StatusCode pgtype.Int4 `json:"status_code"`
+	Attempts   int32       `json:"attempts"`
+	LastError  pgtype.Text `json:"last_error"`
+	ID         uuid.UUID   `json:"id"`
+}
+
+func (q *Queries) UpdateWebhookDeliveryOutcomeQuery(ctx context.Context, db DBTX, arg UpdateWebhookDeliveryOutcomeQueryParams) error {
+	_, err := db.Exec(ctx, updateWebhookDeliveryOutcomeQuery,
+		arg.Outcome,
+		arg.StatusCode,
+		arg.Attempts,
+		arg.LastError,
+		arg.ID,
+	)
+	return err
+}
+
 const verifyRepositoryQuery = `-- name: VerifyRepositoryQuery :one
 UPDATE repository 
   SET linked = TRUE
@@ -0,0 +1,32 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Raw archive of every webhook delivery received from GitHub. Deliveries can
+-- be fed back through the webhook handler with `alfred replay`.
+CREATE TABLE IF NOT EXISTS webhook_deliveries(
+  id UUID DEFAULT gen_random_uuid(),
+  delivery_id TEXT NOT NULL,
+  request_id TEXT NOT NULL,
+  event_type TEXT NOT NULL,
+  headers JSONB NOT NULL,
+  payload BYTEA NOT NULL,
+  outcome TEXT NOT NULL DEFAULT 'received',
+  status_code INTEGER,
+  received_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  processed_at TIMESTAMP,
+
+  CONSTRAINT "webhook_deliveries_pkey" PRIMARY KEY (id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS webhook_deliveries_delivery_id_idx
+  ON webhook_deliveries(delivery_id);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_received_at_idx
+  ON webhook_deliveries(received_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS webhook_deliveries;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Archived deliveries double as the durable work queue. Rows are inserted as
+-- 'queued' and picked up by the background workers in order of arrival.
+ALTER TABLE webhook_deliveries
+  ADD COLUMN ordering_key TEXT NOT NULL DEFAULT '',
+  ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN last_error TEXT,
+  ALTER COLUMN outcome SET DEFAULT 'queued';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS webhook_deliveries_queued_idx
+  ON webhook_deliveries(received_at)
+  WHERE outcome IN ('queued', 'processing');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS webhook_deliveries_queued_idx;
+ALTER TABLE webhook_deliveries
+  DROP COLUMN ordering_key,
+  DROP COLUMN attempts,
+  DROP COLUMN last_error,
+  ALTER COLUMN outcome SET DEFAULT 'received';
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Valkey writes that must happen if and only if a transaction commits. They
+-- are inserted in the same transaction and published by the outbox relay.
+CREATE TABLE IF NOT EXISTS outbox_entries(
+  id BIGSERIAL NOT NULL,
+  command TEXT NOT NULL,
+  key TEXT NOT NULL,
+  value TEXT NOT NULL,
+  increment DOUBLE PRECISION NOT NULL DEFAULT 0,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  published_at TIMESTAMP,
+
+  CONSTRAINT "outbox_entries_pkey" PRIMARY KEY (id),
+  CONSTRAINT "outbox_entries_command_check" CHECK (command IN ('XADD', 'ZINCRBY'))
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS outbox_entries_pending_idx
+  ON outbox_entries(id)
+  WHERE published_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS outbox_entries;
+-- +goose StatementEnd
@@ -0,0 +1,45 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Ledger of stream publishes and webhook deliveries that could not be
+-- processed after all retries. Inspected and retried with `alfred deadletter`
+CREATE TABLE IF NOT EXISTS dead_letters(
+  id BIGSERIAL NOT NULL,
+  kind TEXT NOT NULL,
+  source TEXT NOT NULL,
+  reference TEXT NOT NULL,
+  payload TEXT NOT NULL,
+  error TEXT NOT NULL,
+  attempts INTEGER NOT NULL,
+  status TEXT NOT NULL DEFAULT 'open',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  resolved_at TIMESTAMP,
+
+  CONSTRAINT "dead_letters_pkey" PRIMARY KEY (id),
+  CONSTRAINT "dead_letters_kind_check" CHECK (kind IN ('publish', 'delivery')),
+  CONSTRAINT "dead_letters_status_check"
+    CHECK (status IN ('open', 'retried', 'discarded'))
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Dead outbox entries are skipped by the relay until they are retried
+ALTER TABLE outbox_entries
+  ADD COLUMN dead_at TIMESTAMP;
+
+DROP INDEX IF EXISTS outbox_entries_pending_idx;
+CREATE INDEX IF NOT EXISTS outbox_entries_pending_idx
+  ON outbox_entries(id)
+  WHERE published_at IS NULL AND dead_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS outbox_entries_pending_idx;
+ALTER TABLE outbox_entries
+  DROP COLUMN dead_at;
+CREATE INDEX IF NOT EXISTS outbox_entries_pending_idx
+  ON outbox_entries(id)
+  WHERE published_at IS NULL;
+DROP TABLE IF EXISTS dead_letters;
+-- +goose StatementEnd
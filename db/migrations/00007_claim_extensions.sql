@@ -0,0 +1,18 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Extensions granted on a claim so far, and an extension the participant
+-- asked for beyond the limits that is waiting for a maintainer to approve it
+ALTER TABLE issue_claims
+  ADD COLUMN extension_count INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN extended_days INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN pending_extension_days INTEGER;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE issue_claims
+  DROP COLUMN extension_count,
+  DROP COLUMN extended_days,
+  DROP COLUMN pending_extension_days;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- When the participant was reminded that the claim is about to elapse, reset
+-- whenever the claim is extended so that the new deadline gets a reminder too
+ALTER TABLE issue_claims
+  ADD COLUMN reminded_at TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS issue_claims_elapsed_on_idx
+  ON issue_claims (elapsed_on);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS issue_claims_elapsed_on_idx;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE issue_claims
+  DROP COLUMN reminded_at;
+-- +goose StatementEnd
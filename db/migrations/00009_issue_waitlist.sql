@@ -0,0 +1,19 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Participants waiting for a claimed issue, served in order of id
+CREATE TABLE IF NOT EXISTS issue_waitlist(
+  id BIGSERIAL,
+  issue_url TEXT NOT NULL,
+  ghUsername TEXT NOT NULL,
+  queued_on TIMESTAMP NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT "issue_waitlist_pkey" PRIMARY KEY (id),
+  CONSTRAINT "issue_waitlist_issue_url_ghUsername_key" UNIQUE (issue_url, ghUsername)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS issue_waitlist;
+-- +goose StatementEnd
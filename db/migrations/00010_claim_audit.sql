@@ -0,0 +1,26 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Claims handed out or taken away by maintainers with /assign @participant
+-- and /unassign @participant
+CREATE TABLE IF NOT EXISTS claim_audit(
+  id BIGSERIAL,
+  issue_url TEXT NOT NULL,
+  ghUsername TEXT NOT NULL,
+  action TEXT NOT NULL CHECK (action IN ('assign', 'unassign')),
+  performed_by TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT "claim_audit_pkey" PRIMARY KEY (id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS claim_audit_issue_url_idx
+  ON claim_audit (issue_url);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS claim_audit;
+-- +goose StatementEnd
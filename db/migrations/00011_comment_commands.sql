@@ -0,0 +1,50 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- A reversal is written as a new entry pointing at the one it cancels out,
+-- each entry can be reversed only once
+ALTER TABLE bounty_log
+  ADD COLUMN IF NOT EXISTS reverses_id INTEGER,
+  ADD CONSTRAINT "bounty_log_reverses_id_key" UNIQUE (reverses_id),
+  ADD CONSTRAINT "bounty_log_reverses_fkey"
+    FOREIGN KEY (reverses_id)
+      REFERENCES bounty_log(id)
+        ON DELETE RESTRICT;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Bounties, penalties and badges handed out through issue comments. A
+-- redelivered comment conflicts on (comment_id, line) instead of being applied
+-- twice, and the rows of a deleted comment tell what to reverse.
+CREATE TABLE IF NOT EXISTS comment_commands(
+  id BIGSERIAL,
+  comment_id BIGINT NOT NULL,
+  line INTEGER NOT NULL,
+  command TEXT NOT NULL,
+  issue_url TEXT NOT NULL,
+  ghUsername TEXT NOT NULL,
+  issued_by TEXT NOT NULL,
+  bounty_log_id INTEGER,
+  reversed_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT "comment_commands_pkey" PRIMARY KEY (id),
+  CONSTRAINT "comment_commands_line_key" UNIQUE (comment_id, line),
+  CONSTRAINT "comment_commands_bounty_log_fkey"
+    FOREIGN KEY (bounty_log_id)
+      REFERENCES bounty_log(id)
+        ON DELETE RESTRICT
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS comment_commands;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE bounty_log
+  DROP CONSTRAINT IF EXISTS "bounty_log_reverses_fkey",
+  DROP CONSTRAINT IF EXISTS "bounty_log_reverses_id_key",
+  DROP COLUMN IF EXISTS reverses_id;
+-- +goose StatementEnd
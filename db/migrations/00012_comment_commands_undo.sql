@@ -0,0 +1,12 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- /undo looks up the latest command a maintainer issued on an issue
+CREATE INDEX IF NOT EXISTS comment_commands_issued_idx
+  ON comment_commands (issue_url, issued_by, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS comment_commands_issued_idx;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Assignments passed on to DevPool that GitHub has not reported back yet.
+-- They hold the issue and count towards the claim limits of the participant
+-- until the assigned webhook records the claim or they expire. An issue has
+-- at most one, an expired one is taken over by the next assignment.
+CREATE TABLE IF NOT EXISTS pending_claims(
+  id BIGSERIAL,
+  issue_url TEXT NOT NULL,
+  ghUsername TEXT NOT NULL,
+  requested_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  expires_on TIMESTAMP NOT NULL,
+
+  CONSTRAINT "pending_claims_pkey" PRIMARY KEY (id),
+  CONSTRAINT "pending_claims_issue_url_key" UNIQUE (issue_url)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS pending_claims_ghUsername_idx
+  ON pending_claims (ghUsername);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS pending_claims;
+-- +goose StatementEnd
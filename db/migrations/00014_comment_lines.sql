@@ -0,0 +1,20 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Lines of issue comments whose command has been handled, whether it was
+-- applied or turned down. A line is written in the same transaction as what
+-- its command did, so a comment that is delivered again after a failure only
+-- runs the lines that did not get through.
+CREATE TABLE IF NOT EXISTS comment_lines(
+  comment_id BIGINT NOT NULL,
+  line INTEGER NOT NULL,
+  handled_on TIMESTAMP NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT "comment_lines_pkey" PRIMARY KEY (comment_id, line)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS comment_lines;
+-- +goose StatementEnd
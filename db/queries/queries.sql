@@ -32,6 +32,19 @@ SET
 WHERE url = $2
 RETURNING url;
 
+-- name: GetIssueDifficultyQuery :one
+SELECT difficulty FROM issues
+WHERE url = $1;
+
+-- name: GetClaimableIssueQuery :one
+SELECT
+    i.difficulty,
+    COALESCE(i.resolved, FALSE)::BOOLEAN AS resolved,
+    r.on_display
+FROM issues i
+JOIN repository r ON r.url = i.repoUrl
+WHERE i.url = $1;
+
 -- name: CheckIfTagExistInIssueQuery :one
 SELECT EXISTS (
   SELECT 1
@@ -47,17 +60,17 @@ SET tags = array_append(tags, $1),
 WHERE url = $2
 RETURNING tags;
 
--- name: IssueAssignQuery :exec
+-- name: IssueAssignQuery :execrows
 INSERT INTO issue_claims (
     ghUsername,
     issue_url,
     claimed_on,
     elapsed_on
-) VALUES (
-    $1,
-    $2,
-    $3,
-    $4
+)
+SELECT $1, $2, $3, $4
+WHERE NOT EXISTS (
+    SELECT 1 FROM issue_claims
+    WHERE issue_url = $2 AND elapsed_on > NOW()
 );
 
 -- name: IssueUnassignQuery :one
@@ -84,15 +97,169 @@ WHERE
     url = $1
 RETURNING url;
 
+-- name: GetActiveClaimQuery :one
+SELECT * FROM issue_claims
+WHERE
+    ghUsername = $1
+    AND issue_url = $2
+    AND elapsed_on > NOW()
+FOR UPDATE;
+
 -- name: ExtendClaimQuery :one
 UPDATE issue_claims
 SET
-    elapsed_on = elapsed_on + make_interval(days => $1)
+    elapsed_on = elapsed_on + make_interval(days => sqlc.arg(days)::INTEGER),
+    extension_count = extension_count + 1,
+    extended_days = extended_days + sqlc.arg(days)::INTEGER,
+    pending_extension_days = NULL,
+    reminded_at = NULL
+WHERE
+    id = sqlc.arg(id)
+RETURNING elapsed_on;
+
+-- name: RequestClaimExtensionQuery :exec
+UPDATE issue_claims
+SET
+    pending_extension_days = $1
+WHERE
+    id = $2;
+
+-- name: RescheduleClaimsQuery :execrows
+UPDATE issue_claims
+SET
+    elapsed_on = claimed_on + make_interval(days => sqlc.arg(days)::INTEGER + extended_days),
+    reminded_at = NULL
+WHERE
+    issue_url = sqlc.arg(issue_url)
+    AND elapsed_on > NOW();
+
+-- name: CountActiveClaimsQuery :many
+SELECT
+    UPPER(COALESCE(i.difficulty, 'EASY'))::TEXT AS difficulty,
+    COUNT(*) AS claims
+FROM (
+    SELECT issue_url FROM issue_claims
+    WHERE ghUsername = $1 AND elapsed_on > NOW()
+    UNION
+    SELECT issue_url FROM pending_claims
+    WHERE ghUsername = $1 AND expires_on > NOW()
+) c
+LEFT JOIN issues i ON i.url = c.issue_url
+GROUP BY 1;
+
+-- name: LockParticipantClaimsQuery :exec
+SELECT pg_advisory_xact_lock(hashtext(sqlc.arg(ghusername)::TEXT));
+
+-- name: LockIssueClaimQuery :exec
+SELECT pg_advisory_xact_lock(hashtext(sqlc.arg(issue_url)::TEXT));
+
+-- name: AddPendingClaimQuery :execrows
+INSERT INTO pending_claims (issue_url, ghUsername, expires_on)
+VALUES (
+    sqlc.arg(issue_url),
+    sqlc.arg(ghusername),
+    NOW() + make_interval(secs => sqlc.arg(timeout)::FLOAT8)
+)
+ON CONFLICT (issue_url) DO UPDATE
+SET
+    ghUsername = EXCLUDED.ghUsername,
+    requested_on = NOW(),
+    expires_on = EXCLUDED.expires_on
 WHERE
-    ghUsername = $2 
-    AND issue_url = $3
+    pending_claims.expires_on <= NOW()
+    OR pending_claims.ghUsername = EXCLUDED.ghUsername;
+
+-- name: DeletePendingClaimQuery :execrows
+DELETE FROM pending_claims
+WHERE issue_url = $1 AND ghUsername = $2;
+
+-- name: ClearPendingClaimQuery :exec
+DELETE FROM pending_claims
+WHERE issue_url = $1;
+
+-- name: GetExpiredPendingClaimsQuery :many
+SELECT * FROM pending_claims
+WHERE expires_on <= NOW()
+ORDER BY expires_on
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: GetIssueClaimantQuery :one
+SELECT ghUsername FROM (
+    SELECT ghUsername, claimed_on AS since FROM issue_claims
+    WHERE issue_url = $1 AND elapsed_on > NOW()
+    UNION ALL
+    SELECT ghUsername, requested_on AS since FROM pending_claims
+    WHERE issue_url = $1 AND expires_on > NOW()
+) c
+ORDER BY since
+LIMIT 1;
+
+-- name: AddToWaitlistQuery :exec
+INSERT INTO issue_waitlist (issue_url, ghUsername)
+VALUES ($1, $2)
+ON CONFLICT (issue_url, ghUsername) DO NOTHING;
+
+-- name: CountWaitlistQuery :one
+SELECT COUNT(*) FROM issue_waitlist
+WHERE issue_url = $1;
+
+-- name: GetWaitlistPositionQuery :one
+SELECT COUNT(*) FROM issue_waitlist
+WHERE
+    issue_url = sqlc.arg(issue_url)
+    AND id <= (
+        SELECT id FROM issue_waitlist
+        WHERE issue_url = sqlc.arg(issue_url) AND ghUsername = sqlc.arg(ghUsername)
+    );
+
+-- name: GetWaitlistQuery :many
+SELECT * FROM issue_waitlist
+WHERE issue_url = $1
+ORDER BY id
+FOR UPDATE;
+
+-- name: DeleteWaitlistEntryQuery :exec
+DELETE FROM issue_waitlist
+WHERE id = $1;
+
+-- name: LeaveWaitlistQuery :execrows
+DELETE FROM issue_waitlist
+WHERE issue_url = $1 AND ghUsername = $2;
+
+-- name: ClearWaitlistQuery :execrows
+DELETE FROM issue_waitlist
+WHERE issue_url = $1;
+
+-- name: AddClaimAuditQuery :exec
+INSERT INTO claim_audit (issue_url, ghUsername, action, performed_by)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetExpiredClaimsQuery :many
+SELECT * FROM issue_claims
+WHERE elapsed_on <= NOW()
+ORDER BY elapsed_on
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: GetClaimsDueReminderQuery :many
+SELECT * FROM issue_claims
+WHERE
+    reminded_at IS NULL
     AND elapsed_on > NOW()
-returning ghUsername;
+    AND elapsed_on <= NOW() + make_interval(secs => sqlc.arg(remind_before)::FLOAT8)
+ORDER BY elapsed_on
+LIMIT sqlc.arg(batch_size)
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkClaimRemindedQuery :exec
+UPDATE issue_claims
+SET reminded_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteClaimQuery :exec
+DELETE FROM issue_claims
+WHERE id = $1;
 
 -- name: AddSolutionQuery :one
 INSERT INTO solutions (url, repo_url, ghUsername)
@@ -141,6 +308,226 @@ SET
 WHERE ghUsername = $2
 RETURNING bounty;
 
--- name: AddBountyLogQuery :exec
+-- name: AddBountyLogQuery :one
 INSERT INTO bounty_log (ghUsername, dispatched_by, proof_url, amount)
+VALUES ($1, $2, $3, $4)
+RETURNING id;
+
+-- name: AddBountyReversalQuery :one
+INSERT INTO bounty_log (ghUsername, dispatched_by, proof_url, amount, reverses_id)
+SELECT ghUsername, sqlc.arg(dispatched_by), proof_url, -amount, id
+FROM bounty_log
+WHERE id = sqlc.arg(id)
+RETURNING id, ghUsername, amount;
+
+-- name: AddCommentCommandQuery :one
+INSERT INTO comment_commands (
+    comment_id,
+    line,
+    command,
+    issue_url,
+    ghUsername,
+    issued_by,
+    bounty_log_id
+) VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+ON CONFLICT (comment_id, line) DO NOTHING
+RETURNING id;
+
+-- name: GetCommentCommandsQuery :many
+SELECT * FROM comment_commands
+WHERE comment_id = $1 AND reversed_at IS NULL AND command <> '/undo'
+ORDER BY line
+FOR UPDATE;
+
+-- name: GetLastCommentCommandQuery :one
+SELECT * FROM comment_commands
+WHERE
+    issue_url = sqlc.arg(issue_url)
+    AND issued_by = sqlc.arg(issued_by)
+    AND reversed_at IS NULL
+    AND command <> '/undo'
+    AND created_at > NOW() - make_interval(secs => sqlc.arg(window)::FLOAT8)
+ORDER BY created_at DESC, id DESC
+LIMIT 1
+FOR UPDATE;
+
+-- name: MarkCommentCommandReversedQuery :exec
+UPDATE comment_commands
+SET
+    reversed_at = NOW()
+WHERE id = $1;
+
+-- name: MarkCommentLineQuery :execrows
+INSERT INTO comment_lines (comment_id, line)
+VALUES ($1, $2)
+ON CONFLICT (comment_id, line) DO NOTHING;
+
+-- name: GetCommentLinesQuery :many
+SELECT line FROM comment_lines
+WHERE comment_id = $1
+ORDER BY line;
+
+-- name: AddWebhookDeliveryQuery :one
+INSERT INTO webhook_deliveries (
+    delivery_id,
+    request_id,
+    event_type,
+    ordering_key,
+    headers,
+    payload,
+    outcome
+) VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING id;
+
+-- name: UpdateWebhookDeliveryOutcomeQuery :exec
+UPDATE webhook_deliveries
+SET
+    outcome = $1,
+    status_code = $2,
+    attempts = $3,
+    last_error = $4,
+    processed_at = NOW()
+WHERE id = $5;
+
+-- name: GetWebhookDeliveryQuery :one
+SELECT * FROM webhook_deliveries
+WHERE delivery_id = $1
+ORDER BY received_at DESC
+LIMIT 1;
+
+-- name: GetWebhookDeliveriesInRangeQuery :many
+SELECT * FROM webhook_deliveries
+WHERE
+    received_at >= sqlc.arg(from_time)
+    AND received_at < sqlc.arg(to_time)
+ORDER BY received_at;
+
+-- name: ClaimQueuedDeliveriesQuery :many
+UPDATE webhook_deliveries
+SET outcome = 'processing'
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE outcome = 'queued'
+    ORDER BY received_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RequeueProcessingDeliveriesQuery :execrows
+UPDATE webhook_deliveries
+SET outcome = 'queued'
+WHERE outcome = 'processing';
+
+-- name: AddOutboxEntryQuery :exec
+INSERT INTO outbox_entries (command, key, value, increment)
 VALUES ($1, $2, $3, $4);
+
+-- name: GetPendingOutboxEntriesQuery :many
+SELECT * FROM outbox_entries
+WHERE published_at IS NULL AND dead_at IS NULL
+ORDER BY id
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkOutboxEntryPublishedQuery :exec
+UPDATE outbox_entries
+SET
+    attempts = attempts + 1,
+    last_error = NULL,
+    published_at = NOW()
+WHERE id = $1;
+
+-- name: MarkOutboxEntryFailedQuery :exec
+UPDATE outbox_entries
+SET
+    attempts = attempts + 1,
+    last_error = $1
+WHERE id = $2;
+
+-- name: MarkOutboxEntryDeadQuery :exec
+UPDATE outbox_entries
+SET
+    attempts = attempts + 1,
+    last_error = $1,
+    dead_at = NOW()
+WHERE id = $2;
+
+-- name: ReviveOutboxEntryQuery :execrows
+UPDATE outbox_entries
+SET
+    attempts = 0,
+    dead_at = NULL
+WHERE id = $1 AND dead_at IS NOT NULL;
+
+-- name: RequeueWebhookDeliveryQuery :execrows
+UPDATE webhook_deliveries
+SET
+    outcome = 'queued',
+    attempts = 0
+WHERE id = $1 AND outcome = 'failed';
+
+-- name: AddDeadLetterQuery :one
+INSERT INTO dead_letters (
+    kind,
+    source,
+    reference,
+    payload,
+    error,
+    attempts
+) VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING id;
+
+-- name: ListDeadLettersQuery :many
+SELECT * FROM dead_letters
+WHERE status = $1
+ORDER BY id;
+
+-- name: GetDeadLetterQuery :one
+SELECT * FROM dead_letters
+WHERE id = $1;
+
+-- name: ResolveDeadLetterQuery :one
+UPDATE dead_letters
+SET
+    status = $1,
+    resolved_at = NOW()
+WHERE id = $2 AND status = 'open'
+RETURNING id;
+
+-- name: GetOutboxLagQuery :one
+SELECT
+    COUNT(*) AS pending,
+    COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(created_at)), 0)::FLOAT8 AS oldest_age
+FROM outbox_entries
+WHERE published_at IS NULL AND dead_at IS NULL;
+
+-- name: GetQueueLagQuery :one
+SELECT
+    COUNT(*) AS pending,
+    COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(received_at)), 0)::FLOAT8 AS oldest_age
+FROM webhook_deliveries
+WHERE outcome IN ('queued', 'processing');
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/IAmRiteshKoushik/alfred/cmd"
+	db "github.com/IAmRiteshKoushik/alfred/db/gen"
+	"github.com/IAmRiteshKoushik/alfred/worker"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const deadLetterUsage = `Usage: alfred deadletter <action> [flags]
+
+Actions:
+  list [-status open|retried|discarded]  List dead letters, open ones by default
+  show <id>                              Print a dead letter with its payload
+  retry <id>                             Hand it back to the outbox relay or delivery queue
+  discard <id>                           Mark it as resolved without retrying`
+
+// runDeadLetter lets an operator work through the dead letters. Retried
+// entries are picked up by the running server on its next poll, if they fail
+// again a new dead letter is recorded.
+//
+//	alfred deadletter list
+//	alfred deadletter retry 42
+func runDeadLetter(args []string) error {
+	if len(args) == 0 {
+		fmt.Println(deadLetterUsage)
+		return errors.New("missing action")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	action, args := args[0], args[1:]
+	if action == "list" {
+		return listDeadLetters(ctx, args)
+	}
+
+	if len(args) != 1 {
+		fmt.Println(deadLetterUsage)
+		return fmt.Errorf("%s needs exactly one dead letter id", action)
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid dead letter id %q", args[0])
+	}
+	switch action {
+	case "show":
+		return showDeadLetter(ctx, id)
+	case "retry":
+		return resolveDeadLetter(ctx, id, worker.DeadRetried)
+	case "discard":
+		return resolveDeadLetter(ctx, id, worker.DeadDiscarded)
+	default:
+		fmt.Println(deadLetterUsage)
+		return fmt.Errorf("unknown action %q", action)
+	}
+}
+
+func listDeadLetters(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("deadletter list", flag.ContinueOnError)
+	status := flags.String("status", worker.DeadOpen, "only list dead letters in this state")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	letters, err := db.New().ListDeadLettersQuery(ctx, cmd.DBPool, *status)
+	if err != nil {
+		return fmt.Errorf("could not fetch dead letters: %w", err)
+	}
+	for _, letter := range letters {
+		fmt.Printf("%d\t%s\t%s\t%s\t%s\t%d\t%s\n", letter.ID,
+			letter.CreatedAt.Time.Format(time.RFC3339), letter.Kind, letter.Source,
+			letter.Reference, letter.Attempts, letter.Error)
+	}
+	fmt.Printf("%d %s dead letters\n", len(letters), *status)
+	return nil
+}
+
+func showDeadLetter(ctx context.Context, id int64) error {
+	letter, err := db.New().GetDeadLetterQuery(ctx, cmd.DBPool, id)
+	if err != nil {
+		return fmt.Errorf("could not find dead letter %d: %w", id, err)
+	}
+	fmt.Printf("id:         %d\n", letter.ID)
+	fmt.Printf("kind:       %s\n", letter.Kind)
+	fmt.Printf("source:     %s\n", letter.Source)
+	fmt.Printf("reference:  %s\n", letter.Reference)
+	fmt.Printf("status:     %s\n", letter.Status)
+	fmt.Printf("attempts:   %d\n", letter.Attempts)
+	fmt.Printf("created at: %s\n", letter.CreatedAt.Time.Format(time.RFC3339))
+	if letter.ResolvedAt.Valid {
+		fmt.Printf("resolved:   %s\n", letter.ResolvedAt.Time.Format(time.RFC3339))
+	}
+	fmt.Printf("error:      %s\n", letter.Error)
+	fmt.Printf("payload:\n%s\n", letter.Payload)
+	return nil
+}
+
+// resolveDeadLetter closes an open dead letter. A retry first hands the
+// original outbox entry or delivery back to the server.
+func resolveDeadLetter(ctx context.Context, id int64, status string) error {
+	tx, err := cmd.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	q := db.New()
+	letter, err := q.GetDeadLetterQuery(ctx, tx, id)
+	if err != nil {
+		return fmt.Errorf("could not find dead letter %d: %w", id, err)
+	}
+	if letter.Status != worker.DeadOpen {
+		return fmt.Errorf("dead letter %d is already %s", id, letter.Status)
+	}
+
+	if status == worker.DeadRetried {
+		if err := retryDeadLetter(ctx, tx, letter); err != nil {
+			return err
+		}
+	}
+	_, err = q.ResolveDeadLetterQuery(ctx, tx, db.ResolveDeadLetterQueryParams{
+		Status: status,
+		ID:     id,
+	})
+	if err != nil {
+		return fmt.Errorf("could not resolve dead letter %d: %w", id, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	fmt.Printf("Dead letter %d %s\n", id, status)
+	return nil
+}
+
+func retryDeadLetter(ctx context.Context, tx pgx.Tx, letter db.DeadLetter) error {
+	q := db.New()
+	var (
+		n   int64
+		err error
+	)
+	switch letter.Kind {
+	case worker.DeadPublish:
+		entryId, perr := strconv.ParseInt(letter.Reference, 10, 64)
+		if perr != nil {
+			return fmt.Errorf("invalid outbox entry id %q", letter.Reference)
+		}
+		n, err = q.ReviveOutboxEntryQuery(ctx, tx, entryId)
+	case worker.DeadDelivery:
+		deliveryId, perr := uuid.Parse(letter.Reference)
+		if perr != nil {
+			return fmt.Errorf("invalid delivery id %q", letter.Reference)
+		}
+		n, err = q.RequeueWebhookDeliveryQuery(ctx, tx, deliveryId)
+	default:
+		return fmt.Errorf("unknown dead letter kind %s", letter.Kind)
+	}
+	if err != nil {
+		return fmt.Errorf("could not retry dead letter %d: %w", letter.ID, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s %s is no longer failed, discard the dead letter instead",
+			letter.Kind, letter.Reference)
+	}
+	return nil
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
-	"io"
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/IAmRiteshKoushik/alfred/bootstrap"
@@ -12,8 +17,11 @@ import (
 	"github.com/IAmRiteshKoushik/alfred/controller"
 	"github.com/IAmRiteshKoushik/alfred/middleware"
 	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/IAmRiteshKoushik/alfred/worker"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
@@ -27,14 +35,22 @@ func main() {
 	}
 
 	// Setup logger
-	f, err := os.OpenFile("app.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	pkg.Log, err = pkg.NewLoggerService(cmd.AppConfig.Log)
 	if err != nil {
 		log.Printf(failMsg, err)
+		return
 	}
-	defer f.Close()
-	pkg.Log = pkg.NewLoggerService(cmd.AppConfig.Environment, f)
 	pkg.Log.SetupInfo("[ACTIVE]: Logging service is online.")
 
+	// Setup tracing before any client is created
+	shutdownTracing, err := cmd.InitTracing()
+	if err != nil {
+		pkg.Log.SetupFail("[CRASH]: Could not initialize tracing", err)
+		return
+	}
+	defer shutdownTracing(context.Background())
+	pkg.Log.SetupInfo("[ACTIVE]: Tracing is online.")
+
 	// Setup connection pooling for Postgres
 	pool, err := cmd.InitDB()
 	if err != nil {
@@ -58,22 +74,56 @@ func main() {
 		return
 	}
 
-	// Setup gin server
-	ginLogs, err := os.Create("gin.log")
-	if err != nil {
-		pkg.Log.SetupFail("Error creating log file for Gin", err)
+	// Subcommands run against the same services as the server and then exit
+	if len(os.Args) > 1 {
+		err := runSubcommand(os.Args[1], os.Args[2:])
+		cmd.DBPool.Close()
+		cmd.CloseValkey(pkg.Valkey)
+		if err != nil {
+			pkg.Log.SetupFail("[FAIL]: "+os.Args[1]+" did not complete", err)
+			shutdownTracing(context.Background())
+			os.Exit(1)
+		}
 		return
 	}
-	defer ginLogs.Close()
-	multiWriter := io.MultiWriter(os.Stdout, ginLogs)
-	gin.DefaultWriter = multiWriter
-	gin.DefaultErrorWriter = multiWriter
+
+	// Setup background workers. They get their own context so that they are
+	// only stopped after the server has drained its in-flight requests.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	startWorker := func(name string, run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workerCtx)
+		}()
+		pkg.Log.SetupInfo("[ACTIVE]: " + name + " is online.")
+	}
+	startWorker("Delivery queue", worker.NewDeliveryQueue(controller.ProcessDelivery).Run)
+	startWorker("Outbox relay", worker.NewOutboxRelay().Run)
+	startWorker("Stream trimmer", worker.NewStreamTrimmer().Run)
+	startWorker("Claim sweeper", worker.NewClaimSweeper(controller.ReleaseExpiredClaim,
+		controller.RemindClaim, controller.ReleaseExpiredPendingClaim).Run)
+
+	// Setup metrics collected at scrape time
+	var streams []string
+	for _, s := range bootstrap.Structures {
+		if s.Type == "stream" {
+			streams = append(streams, s.Name)
+		}
+	}
+	prometheus.MustRegister(cmd.PoolCollector{}, cmd.StreamCollector{
+		Client:  pkg.Valkey,
+		Streams: streams,
+	})
+
+	// Setup gin server, requests are logged through the structured logger
 	gin.SetMode(gin.ReleaseMode)
 
-	router := gin.Default()
+	router := gin.New()
 	router.Use(pkg.TagRequestWithId)
+	router.Use(middleware.RequestLogger)
 	router.Use(middleware.PanicRecovery)
-	router.Use(gin.Logger())
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"*"},
@@ -82,20 +132,70 @@ func main() {
 		MaxAge:           12 * time.Hour,
 	}))
 
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	router.GET("/healthz", controller.HealthzHandler)
+	router.GET("/readyz", controller.ReadyzHandler)
 	router.GET("/api/test", controller.TestEndpointHandler)
-	router.POST("/api/webhook", controller.WebhookHandler)
+	router.GET("/api/schemas/:name", controller.SchemaHandler)
+	router.POST("/api/webhook",
+		middleware.VerifySignature,
+		middleware.DeduplicateDelivery,
+		controller.WebhookHandler,
+	)
 	// router.POST("/api/webhook/install", controller.InstallationHandler)
 
 	port := strconv.Itoa(cmd.AppConfig.ServerPort)
 	pkg.Log.SetupInfo("[ON]: Server configured and starting on PORT:" + port)
 
-	routerErr := router.Run(":" + port)
-	if routerErr != nil {
-		pkg.Log.SetupFail("[FAIL]: Server failed to start due to:", err)
-		panic(routerErr)
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: router,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	// Wait for a deploy or Ctrl-C. A second signal kills the process.
+	signals, stopSignals := signal.NotifyContext(context.Background(),
+		syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-signals.Done():
+		pkg.Log.SetupInfo("[OFF]: Shutdown signal received, draining.")
+	case err := <-serverErr:
+		pkg.Log.SetupFail("[FAIL]: Server failed due to:", err)
+	}
+	stopSignals()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(),
+		cmd.AppConfig.ShutdownTimeout)
+	defer cancel()
+
+	// Stop accepting deliveries and let the in-flight handlers finish
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		pkg.Log.SetupFail("[FAIL]: Server did not drain in time", err)
 	}
 	pkg.Log.SetupInfo("[DEACTIVE]: Server offline.")
 
+	// Workers finish the delivery or batch they are on, anything still
+	// queued is picked up again on the next start.
+	stopWorkers()
+	drained := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		pkg.Log.SetupInfo("[DEACTIVE]: Background workers stopped.")
+	case <-shutdownCtx.Done():
+		pkg.Log.SetupWarn("[DEACTIVE]: Background workers did not stop in time.")
+	}
+
+	cmd.DBPool.Close()
+	pkg.Log.SetupInfo("[DEACTIVE]: Database pool closed.")
 	cmd.CloseValkey(pkg.Valkey)
 	pkg.Log.SetupInfo("[DEACTIVE]: Valkey offline.")
 	pkg.Log.SetupInfo("[DEACTIVE]: Logging service offline.")
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/IAmRiteshKoushik/alfred/cmd"
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v74/github"
+)
+
+// DeduplicateDelivery makes webhook processing safe to replay. GitHub retries
+// deliveries on timeouts and maintainers can redeliver from the UI, every
+// delivery carries the same X-GitHub-Delivery id across those attempts, so
+// the id is recorded in Valkey and any repeat is flagged for the webhook
+// handler, which archives it as a duplicate without running the handlers a
+// second time.
+func DeduplicateDelivery(c *gin.Context) {
+	deliveryId := c.GetHeader(github.DeliveryIDHeader)
+	if deliveryId == "" {
+		pkg.Log.Warn(c, "Missing X-GitHub-Delivery header")
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"message": "Missing X-GitHub-Delivery header",
+		})
+		return
+	}
+
+	key := pkg.DeliveryKeyPrefix + deliveryId
+	fresh, err := cmd.MarkDelivery(pkg.Valkey, key, cmd.AppConfig.DeliveryTTL)
+	if err != nil {
+		pkg.Log.Error(c, "Failed to record delivery "+deliveryId, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if !fresh {
+		pkg.MarkDuplicateDelivery(c)
+		c.Next()
+		return
+	}
+
+	c.Next()
+
+	// Handlers run later on the delivery queue, which releases the id of a
+	// delivery that fails there. Here it is only released when the delivery
+	// could not be stored, so that GitHub's redelivery is let through.
+	if c.Writer.Status() >= http.StatusInternalServerError {
+		if err := cmd.ForgetDelivery(pkg.Valkey, key); err != nil {
+			pkg.Log.Error(c, "Failed to release delivery "+deliveryId, err)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"slices"
+	"time"
+
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// Probes and scrapes are only logged when they fail
+var quietPaths = []string{"/healthz", "/readyz", "/metrics"}
+
+// RequestLogger writes one structured access log line per request
+func RequestLogger(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+	if slices.Contains(quietPaths, c.FullPath()) && c.Writer.Status() < 400 {
+		return
+	}
+	pkg.Log.Access(c, time.Since(start))
+}
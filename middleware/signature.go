@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/IAmRiteshKoushik/alfred/cmd"
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v74/github"
+)
+
+// VerifySignature rejects any webhook delivery that is not signed with one of
+// the configured webhook secrets. It must run before the webhook handler so
+// that forged payloads never reach the database or Valkey.
+func VerifySignature(c *gin.Context) {
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		pkg.Log.Error(c, "Error reading request body during signature check", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	// Handlers further down the chain read the body again
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(payload))
+
+	signature := c.GetHeader(github.SHA256SignatureHeader)
+	if signature == "" {
+		pkg.Log.Warn(c, "Missing X-Hub-Signature-256 header")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"message": "Missing X-Hub-Signature-256 header",
+		})
+		return
+	}
+
+	if err := validSignature(signature, payload, cmd.AppConfig.WebhookSecrets); err != nil {
+		pkg.Log.Error(c, "Rejected webhook with invalid signature", err)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"message": "Invalid webhook signature",
+		})
+		return
+	}
+	c.Next()
+}
+
+// validSignature checks the signature against every secret so that deliveries
+// signed with either the old or the new secret pass during a rotation.
+func validSignature(signature string, payload []byte, secrets []string) error {
+	if len(secrets) == 0 {
+		return fmt.Errorf("No webhook secrets configured")
+	}
+	// ValidateSignature also accepts sha1= digests, only SHA-256 is trusted
+	if !strings.HasPrefix(signature, "sha256=") {
+		return fmt.Errorf("Signature does not use SHA-256")
+	}
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		if err := github.ValidateSignature(signature, payload, []byte(secret)); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("Signature did not match any configured secret")
+}
@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/IAmRiteshKoushik/alfred/cmd"
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v74/github"
+)
+
+// Fixtures in testdata/ are signed with this secret. The matching signature
+// for every <name>.json lives next to it in <name>.json.sig
+const fixtureSecret = "alfred-test-secret"
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	f, err := os.CreateTemp("", "alfred-test-*.log")
+	if err != nil {
+		panic(err)
+	}
+	defer os.Remove(f.Name())
+	pkg.Log, err = pkg.NewLoggerService(pkg.LoggerConfig{
+		Level:   "debug",
+		Format:  pkg.LogConsole,
+		Outputs: []string{pkg.LogFile},
+		File:    f.Name(),
+	})
+	if err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func loadFixture(t *testing.T, name string) ([]byte, string) {
+	t.Helper()
+	payload, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("could not read fixture %s: %v", name, err)
+	}
+	sig, err := os.ReadFile(filepath.Join("testdata", name+".sig"))
+	if err != nil {
+		t.Fatalf("could not read signature for %s: %v", name, err)
+	}
+	return payload, strings.TrimSpace(string(sig))
+}
+
+// serve runs the request through VerifySignature followed by a handler that
+// records whether it was reached and echoes back the body it received.
+func serve(secrets []string, body []byte, signature string) (*httptest.ResponseRecorder, bool) {
+	cmd.AppConfig = &cmd.EnvConfig{WebhookSecrets: secrets}
+
+	reached := false
+	router := gin.New()
+	router.POST("/api/webhook", VerifySignature, func(c *gin.Context) {
+		reached = true
+		c.DataFromReader(http.StatusOK, c.Request.ContentLength, "application/json",
+			c.Request.Body, nil)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/webhook", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if signature != "" {
+		req.Header.Set(github.SHA256SignatureHeader, signature)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w, reached
+}
+
+func hmacSHA1(payload []byte, secret string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	comment, commentSig := loadFixture(t, "issue_comment.json")
+	ping, pingSig := loadFixture(t, "ping.json")
+
+	tests := []struct {
+		name      string
+		secrets   []string
+		body      []byte
+		signature string
+		wantCode  int
+	}{
+		{
+			name:      "valid issue comment",
+			secrets:   []string{fixtureSecret},
+			body:      comment,
+			signature: commentSig,
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "valid ping",
+			secrets:   []string{fixtureSecret},
+			body:      ping,
+			signature: pingSig,
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "rotated secret still accepted",
+			secrets:   []string{"new-secret", fixtureSecret},
+			body:      comment,
+			signature: commentSig,
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "missing signature",
+			secrets:   []string{fixtureSecret},
+			body:      comment,
+			signature: "",
+			wantCode:  http.StatusUnauthorized,
+		},
+		{
+			name:      "wrong secret",
+			secrets:   []string{"not-the-secret"},
+			body:      comment,
+			signature: commentSig,
+			wantCode:  http.StatusUnauthorized,
+		},
+		{
+			name:      "signature of another payload",
+			secrets:   []string{fixtureSecret},
+			body:      comment,
+			signature: pingSig,
+			wantCode:  http.StatusUnauthorized,
+		},
+		{
+			name:      "tampered payload",
+			secrets:   []string{fixtureSecret},
+			body:      bytes.Replace(comment, []byte("/bounty 50"), []byte("/bounty 5000"), 1),
+			signature: commentSig,
+			wantCode:  http.StatusUnauthorized,
+		},
+		{
+			name:      "sha256 digest under a sha1 prefix",
+			secrets:   []string{fixtureSecret},
+			body:      comment,
+			signature: "sha1=" + strings.TrimPrefix(commentSig, "sha256="),
+			wantCode:  http.StatusUnauthorized,
+		},
+		{
+			// Matches the payload, only the algorithm is wrong
+			name:      "sha1 signature is not accepted",
+			secrets:   []string{fixtureSecret},
+			body:      comment,
+			signature: "sha1=" + hmacSHA1(comment, fixtureSecret),
+			wantCode:  http.StatusUnauthorized,
+		},
+		{
+			name:      "no secrets configured",
+			secrets:   nil,
+			body:      comment,
+			signature: commentSig,
+			wantCode:  http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, reached := serve(tt.secrets, tt.body, tt.signature)
+			if w.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if reached != (tt.wantCode == http.StatusOK) {
+				t.Fatalf("handler reached = %v for status %d", reached, w.Code)
+			}
+			if reached && !bytes.Equal(w.Body.Bytes(), tt.body) {
+				t.Fatalf("handler did not receive the original body")
+			}
+		})
+	}
+}
@@ -41,9 +41,40 @@ const (
 	// 4. Issue Accepted (normal, bug-report)
 	// 5. Pull Request Opened
 	// 6. Pull Request Merged
+	// Events produced by Alfred are typed and versioned, see controller/live.go
 	// Producers: Alfred (Webhooks), DevPool (GitHub App), Gravemind (Workflows)
 	// Consumer: Pulse (API Server)
 	LiveUpdates = "live-update-stream"
+
+	// Every stream publish or webhook delivery that is given up on is
+	// recorded in the dead_letters table and announced here so that it can
+	// be alerted on. Use `alfred deadletter` to inspect and retry them.
+	//
+	// Producer: Alfred (Webhooks)
+	// Consumer: Operators (Alerting)
+	DeadLetters = "dead-letter-stream"
+
+	// Commands in issue comments that were turned down, whether they could
+	// not be parsed or could not be carried out, so that the bot can reply
+	// on the issue and tell the commentator what went wrong.
+	//
+	// Producer: Alfred (Webhooks)
+	// Consumer: DevPool (GitHub App)
+	CommandRejections = "command-rejected-stream"
+)
+
+// Every X-GitHub-Delivery id that has been processed is stored under this
+// prefix with a TTL so that redeliveries of the same webhook are ignored.
+const DeliveryKeyPrefix = "webhook-delivery:"
+
+// Sorted-set increments relayed from the outbox leave a marker under this
+// prefix so that relaying the same entry twice does not count it twice.
+const OutboxKeyPrefix = "outbox-applied:"
+
+// Valkey commands that can be written to the outbox
+const (
+	OutboxStream    = "XADD"
+	OutboxSortedSet = "ZINCRBY"
 )
 
 // HashSets for normal badges. These act like buckets grouping participants
@@ -0,0 +1,104 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+// Name written into every envelope produced by this service
+const Producer = "alfred"
+
+// Payload types carried inside an Envelope. The payload of every type is
+// described by a JSON Schema at schemas/<type>.v<version>.json
+const (
+	IssueActionEvent     = "issue-action"
+	BountyActionEvent    = "bounty-action"
+	AchievementEvent     = "achievement"
+	SolutionEvent        = "solution"
+	LiveUpdateEvent      = "live-update"
+	DeadLetterEvent      = "dead-letter"
+	CommandRejectedEvent = "command-rejected"
+	ReversalEvent        = "reversal"
+	ClaimStatusEvent     = "claim-status"
+	ClaimReminderEvent   = "claim-reminder"
+)
+
+// Current schema version of every payload type. Bump the version and add a
+// new schema file whenever a field changes meaning or is removed.
+var EventVersions = map[string]int{
+	IssueActionEvent:     2,
+	BountyActionEvent:    1,
+	AchievementEvent:     1,
+	SolutionEvent:        1,
+	LiveUpdateEvent:      1,
+	DeadLetterEvent:      1,
+	CommandRejectedEvent: 1,
+	ReversalEvent:        1,
+	ClaimStatusEvent:     1,
+	ClaimReminderEvent:   1,
+}
+
+// Envelope is the common shape of every message written to a Valkey stream.
+// It is flattened into the fields of the stream entry, with the payload kept
+// as a JSON string under "payload". See schemas/envelope.json
+type Envelope struct {
+	EventID    string          `json:"event_id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	DeliveryID string          `json:"delivery_id"`
+	Producer   string          `json:"producer"`
+	Payload    json.RawMessage `json:"payload"`
+
+	// W3C trace context of the span that produced the event, consumers use
+	// it to continue the trace. Empty when tracing is not set up.
+	Traceparent string `json:"traceparent,omitempty"`
+}
+
+// NewEnvelope wraps a payload of a known type. The delivery id ties the event
+// back to the GitHub webhook delivery that caused it and may be empty for
+// events raised by background jobs. The trace context is taken from ctx.
+func NewEnvelope(ctx context.Context, eventType string, deliveryId string,
+	payload any) (Envelope, error) {
+	version, ok := EventVersions[eventType]
+	if !ok {
+		return Envelope{}, fmt.Errorf("unknown event type %s", eventType)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("failed to marshal %s payload: %w", eventType, err)
+	}
+	return Envelope{
+		EventID:    ksuid.New().String(),
+		Type:       eventType,
+		Version:    version,
+		OccurredAt: time.Now().UTC(),
+		DeliveryID: deliveryId,
+		Producer:   Producer,
+		Payload:    data,
+
+		Traceparent: Traceparent(ctx),
+	}, nil
+}
+
+// Fields returns the envelope as the field-value pairs of a stream entry
+func (e Envelope) Fields() map[string]any {
+	fields := map[string]any{
+		"event_id":    e.EventID,
+		"type":        e.Type,
+		"version":     strconv.Itoa(e.Version),
+		"occurred_at": e.OccurredAt.Format(time.RFC3339Nano),
+		"delivery_id": e.DeliveryID,
+		"producer":    e.Producer,
+		"payload":     string(e.Payload),
+	}
+	if e.Traceparent != "" {
+		fields["traceparent"] = e.Traceparent
+	}
+	return fields
+}
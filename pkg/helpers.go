@@ -20,3 +20,23 @@ func GrabRequestId(c *gin.Context) string {
 	}
 	return fmt.Sprintf("%v", reqId)
 }
+
+const duplicateDeliveryKey = "duplicate_delivery"
+
+// MarkDuplicateDelivery flags the webhook being handled as a repeat of a
+// delivery that was received before
+func MarkDuplicateDelivery(c *gin.Context) {
+	c.Set(duplicateDeliveryKey, true)
+}
+
+// IsDuplicateDelivery reports whether the webhook being handled was flagged
+// by MarkDuplicateDelivery
+func IsDuplicateDelivery(c *gin.Context) bool {
+	return c.GetBool(duplicateDeliveryKey)
+}
+
+// GrabDeliveryId returns the GitHub delivery id of the webhook being handled,
+// empty if the request did not come from GitHub.
+func GrabDeliveryId(c *gin.Context) string {
+	return c.GetHeader("X-GitHub-Delivery")
+}
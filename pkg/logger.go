@@ -1,51 +1,144 @@
 package pkg
 
 import (
-	"errors"
+	"fmt"
 	"io"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	v "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 var Log *LoggerService
 
+// LoggerConfig decides where logs are written and how they look. Files are
+// rotated once they reach MaxSizeMB, rotated files are kept for MaxAgeDays
+// and at most MaxBackups of them are retained.
+type LoggerConfig struct {
+	Level      string
+	Format     string // "json" or "console"
+	Outputs    []string
+	File       string
+	MaxSizeMB  int
+	MaxAgeDays int
+	MaxBackups int
+	Compress   bool
+}
+
+// Log outputs and formats
+const (
+	LogStdout  = "stdout"
+	LogFile    = "file"
+	LogJSON    = "json"
+	LogConsole = "console"
+)
+
+// Fields handlers attach to every log line of a request, see AddLogField
+const (
+	LogRepo  = "repo"
+	LogIssue = "issue"
+	LogActor = "actor"
+)
+
+// Fields the workers attach to their log lines, see With
+const (
+	LogDeliveryId = "delivery_id"
+	LogEventType  = "event_type"
+	LogAttempts   = "attempts"
+	LogCount      = "count"
+	LogStream     = "stream"
+	LogError      = "error"
+)
+
+const logFieldsKey = "log_fields"
+
+func (cfg LoggerConfig) Validate() error {
+	return v.ValidateStruct(&cfg,
+		v.Field(&cfg.Level, v.Required, v.In("trace", "debug", "info", "warn", "error")),
+		v.Field(&cfg.Format, v.Required, v.In(LogJSON, LogConsole)),
+		v.Field(&cfg.Outputs, v.Required, v.Each(v.In(LogStdout, LogFile))),
+		v.Field(&cfg.File, v.When(slices.Contains(cfg.Outputs, LogFile), v.Required)),
+		v.Field(&cfg.MaxSizeMB, v.Min(0)),
+		v.Field(&cfg.MaxAgeDays, v.Min(0)),
+		v.Field(&cfg.MaxBackups, v.Min(0)),
+	)
+}
+
 type LoggerService struct {
 	log zerolog.Logger
-	env string
 }
 
-func NewLoggerService(env string, file *os.File) *LoggerService {
-	var output io.Writer
+func NewLoggerService(cfg LoggerConfig) (*LoggerService, error) {
+	level, err := zerolog.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", cfg.Level, err)
+	}
 
-	if env == "development" {
-		// Logging to both file and std.out during development
-		fileOut := zerolog.ConsoleWriter{
-			Out:        file,
-			TimeFormat: time.RFC3339,
-			NoColor:    true,
+	var outputs []io.Writer
+	for _, name := range cfg.Outputs {
+		var out io.Writer
+		switch name {
+		case LogStdout:
+			out = os.Stdout
+		case LogFile:
+			out = &lumberjack.Logger{
+				Filename:   cfg.File,
+				MaxSize:    cfg.MaxSizeMB,
+				MaxAge:     cfg.MaxAgeDays,
+				MaxBackups: cfg.MaxBackups,
+				Compress:   cfg.Compress,
+			}
+		default:
+			return nil, fmt.Errorf("unknown log output %q", name)
 		}
-		consoleOut := zerolog.ConsoleWriter{
-			Out:        os.Stdout,
-			TimeFormat: time.RFC3339,
+		switch cfg.Format {
+		case LogJSON:
+		case LogConsole:
+			out = zerolog.ConsoleWriter{
+				Out:        out,
+				TimeFormat: time.RFC3339,
+				NoColor:    name != LogStdout,
+			}
+		default:
+			return nil, fmt.Errorf("unknown log format %q", cfg.Format)
 		}
-		output = zerolog.MultiLevelWriter(consoleOut, fileOut)
-
-	} else if env == "production" {
-		// Logging only to file during production
-		output = zerolog.ConsoleWriter{Out: file, TimeFormat: time.RFC3339}
-
-	} else {
-		panic(errors.New("Could not identify environment"))
+		outputs = append(outputs, out)
+	}
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("no log outputs configured")
 	}
 
-	logger := zerolog.New(output).With().Timestamp().Logger()
+	logger := zerolog.New(zerolog.MultiLevelWriter(outputs...)).
+		Level(level).
+		With().Timestamp().Logger()
 	return &LoggerService{
 		log: logger,
-		env: env,
+	}, nil
+}
+
+// AddLogField attaches a field to every later log line of the request
+func AddLogField(c *gin.Context, key string, value string) {
+	if value == "" {
+		return
 	}
+	fields := c.GetStringMapString(logFieldsKey)
+	if fields == nil {
+		fields = map[string]string{}
+		c.Set(logFieldsKey, fields)
+	}
+	fields[key] = value
+}
+
+// With returns a logger that adds the fields to every line it writes. The
+// workers use it with the setup loggers, they have no request to take the
+// delivery or stream from.
+func (l *LoggerService) With(fields map[string]any) *LoggerService {
+	return &LoggerService{log: l.log.With().Fields(fields).Logger()}
 }
 
 // Service setup loggers (not for API use)
@@ -61,51 +154,67 @@ func (l *LoggerService) SetupFail(msg string, err error) {
 	l.log.WithLevel(zerolog.ErrorLevel).Err(err).Msg(msg)
 }
 
-// Loggers for API use
-func (l *LoggerService) Info(c *gin.Context, msg string) {
-	l.log.WithLevel(zerolog.InfoLevel).
+// request starts a log line carrying everything known about the request
+func (l *LoggerService) request(c *gin.Context, level zerolog.Level) *zerolog.Event {
+	event := l.log.WithLevel(level).
 		Str("req_id", GrabRequestId(c)).
 		Str("path", c.FullPath()).
-		Str("method", c.Request.Method).
-		Msgf("%s", msg)
+		Str("method", c.Request.Method)
+	if deliveryId := GrabDeliveryId(c); deliveryId != "" {
+		event = event.Str(LogDeliveryId, deliveryId)
+	}
+	if eventType := c.GetHeader("X-GitHub-Event"); eventType != "" {
+		event = event.Str("event", eventType)
+	}
+	fields := c.GetStringMapString(logFieldsKey)
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		event = event.Str(key, fields[key])
+	}
+	if span := trace.SpanContextFromContext(c.Request.Context()); span.IsValid() {
+		event = event.Str("trace_id", span.TraceID().String())
+	}
+	return event
+}
+
+// Loggers for API use
+func (l *LoggerService) Info(c *gin.Context, msg string) {
+	l.request(c, zerolog.InfoLevel).Msg(msg)
 }
 
 func (l *LoggerService) Debug(c *gin.Context, msg string) {
-	l.log.WithLevel(zerolog.DebugLevel).
-		Str("req_id", GrabRequestId(c)).
-		Str("path", c.FullPath()).
-		Str("method", c.Request.Method).
-		Msgf("%s", msg)
+	l.request(c, zerolog.DebugLevel).Msg(msg)
 }
 
 func (l *LoggerService) Warn(c *gin.Context, msg string) {
-	l.log.WithLevel(zerolog.WarnLevel).
-		Str("req_id", GrabRequestId(c)).
-		Str("path", c.FullPath()).
-		Str("method", c.Request.Method).
-		Msgf("%s", msg)
+	l.request(c, zerolog.WarnLevel).Msg(msg)
 }
 
 func (l *LoggerService) Error(c *gin.Context, msg string, err error) {
-	l.log.WithLevel(zerolog.ErrorLevel).
-		Str("req_id", GrabRequestId(c)).
-		Str("path", c.FullPath()).
-		Str("method", c.Request.Method).
-		Err(err).
-		Msgf("%s", msg)
+	l.request(c, zerolog.ErrorLevel).Err(err).Msg(msg)
 }
 
 func (l *LoggerService) Fatal(c *gin.Context, msg string, err error) {
-	l.log.WithLevel(zerolog.FatalLevel).
-		Str("req_id", GrabRequestId(c)).
-		Err(err).
-		Msgf("%s", msg)
+	l.request(c, zerolog.FatalLevel).Err(err).Msg(msg)
 }
 
 func (l *LoggerService) Success(c *gin.Context) {
-	l.log.WithLevel(zerolog.InfoLevel).
-		Str("req_id", GrabRequestId(c)).
-		Str("path", c.FullPath()).
-		Str("method", c.Request.Method).
-		Msgf("[SUCCESS]: Webhook processed successfully.")
+	l.request(c, zerolog.InfoLevel).Msg("[SUCCESS]: Webhook processed successfully.")
+}
+
+// Access records a completed HTTP request, it replaces gin's text logger
+func (l *LoggerService) Access(c *gin.Context, latency time.Duration) {
+	level := zerolog.InfoLevel
+	if c.Writer.Status() >= 500 {
+		level = zerolog.ErrorLevel
+	}
+	l.request(c, level).
+		Int("status", c.Writer.Status()).
+		Dur("latency", latency).
+		Str("client_ip", c.ClientIP()).
+		Msg("[ACCESS]: " + c.Request.Method + " " + c.Request.URL.Path)
 }
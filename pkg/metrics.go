@@ -0,0 +1,49 @@
+package pkg
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics exposed on /metrics. Collectors that read state at scrape
+// time (pool stats, stream lengths) live in cmd/metrics.go.
+var (
+	WebhooksReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "alfred",
+		Name:      "webhooks_received_total",
+		Help:      "Webhook deliveries accepted, by X-GitHub-Event and action.",
+	}, []string{"event", "action"})
+
+	DeliveriesProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "alfred",
+		Name:      "deliveries_processed_total",
+		Help:      "Final outcome of queued deliveries, by X-GitHub-Event.",
+	}, []string{"event", "outcome"})
+
+	CommandsParsed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "alfred",
+		Name:      "commands_parsed_total",
+		Help:      "Issue comments by the command they were parsed into.",
+	}, []string{"command"})
+
+	HandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "alfred",
+		Name:      "handler_duration_seconds",
+		Help:      "Time taken by the webhook handlers to process a delivery.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"event", "status"})
+
+	TxDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "alfred",
+		Name:      "db_transaction_duration_seconds",
+		Help:      "Time from BEGIN to COMMIT or ROLLBACK of database transactions.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result"})
+
+	XAddDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "alfred",
+		Name:      "valkey_xadd_duration_seconds",
+		Help:      "Time taken by XADD, by stream.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"stream", "result"})
+)
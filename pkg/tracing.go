@@ -0,0 +1,26 @@
+package pkg
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Tracer used for every span started by this service. It follows whichever
+// provider is installed by cmd.InitTracing.
+var Tracer = otel.Tracer("github.com/IAmRiteshKoushik/alfred")
+
+// Traceparent returns the W3C traceparent of the span in ctx, or an empty
+// string when there is none.
+func Traceparent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// ContextWithTraceparent continues the trace described by a traceparent
+func ContextWithTraceparent(ctx context.Context, traceparent string) context.Context {
+	carrier := propagation.MapCarrier{"traceparent": traceparent}
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/IAmRiteshKoushik/alfred/cmd"
+	"github.com/IAmRiteshKoushik/alfred/controller"
+	db "github.com/IAmRiteshKoushik/alfred/db/gen"
+	"github.com/IAmRiteshKoushik/alfred/worker"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// runReplay re-feeds archived deliveries through the webhook handlers. Either
+// a single delivery is picked by its X-GitHub-Delivery id, or every delivery
+// received within a time range is replayed in the order it arrived.
+//
+//	alfred replay -delivery 72d3162e-cc78-11e3-81ab-4c9367dc0958
+//	alfred replay -from 2025-06-01T10:00:00Z -to 2025-06-01T12:00:00Z -failed
+func runReplay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	deliveryId := flags.String("delivery", "", "X-GitHub-Delivery id of the delivery to replay")
+	from := flags.String("from", "", "replay deliveries received at or after this time (RFC3339)")
+	to := flags.String("to", "", "replay deliveries received before this time (RFC3339), defaults to now")
+	failedOnly := flags.Bool("failed", false, "only replay deliveries whose last outcome was a failure")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	q := db.New()
+
+	var deliveries []db.WebhookDelivery
+	switch {
+	case *deliveryId != "":
+		delivery, err := q.GetWebhookDeliveryQuery(ctx, cmd.DBPool, *deliveryId)
+		if err != nil {
+			return fmt.Errorf("could not find delivery %s: %w", *deliveryId, err)
+		}
+		deliveries = append(deliveries, delivery)
+
+	case *from != "":
+		start, err := time.Parse(time.RFC3339, *from)
+		if err != nil {
+			return fmt.Errorf("invalid -from: %w", err)
+		}
+		end := time.Now()
+		if *to != "" {
+			end, err = time.Parse(time.RFC3339, *to)
+			if err != nil {
+				return fmt.Errorf("invalid -to: %w", err)
+			}
+		}
+		// received_at is stored without a zone in server local time
+		deliveries, err = q.GetWebhookDeliveriesInRangeQuery(ctx, cmd.DBPool,
+			db.GetWebhookDeliveriesInRangeQueryParams{
+				FromTime: pgtype.Timestamp{Time: start.Local(), Valid: true},
+				ToTime:   pgtype.Timestamp{Time: end.Local(), Valid: true},
+			})
+		if err != nil {
+			return fmt.Errorf("could not fetch deliveries: %w", err)
+		}
+
+	default:
+		flags.Usage()
+		return errors.New("either -delivery or -from is required")
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	replayed, failed := 0, 0
+	for _, delivery := range deliveries {
+		if *failedOnly && delivery.Outcome != worker.Failed {
+			continue
+		}
+		// The queue still owns these, replaying would process them twice
+		if delivery.Outcome == worker.Queued || delivery.Outcome == worker.Processing {
+			fmt.Printf("%s\t%s\t%s\tskipped, still %s\n",
+				delivery.ReceivedAt.Time.Format(time.RFC3339),
+				delivery.DeliveryID, delivery.EventType, delivery.Outcome)
+			continue
+		}
+		// A repeat of another delivery, that one is replayed instead
+		if delivery.Outcome == worker.Duplicate {
+			fmt.Printf("%s\t%s\t%s\tskipped, duplicate\n",
+				delivery.ReceivedAt.Time.Format(time.RFC3339),
+				delivery.DeliveryID, delivery.EventType)
+			continue
+		}
+		status, err := controller.ReplayDelivery(delivery)
+		if err != nil {
+			return fmt.Errorf("could not replay delivery %s: %w", delivery.DeliveryID, err)
+		}
+		replayed++
+		if status >= 500 {
+			failed++
+		}
+		fmt.Printf("%s\t%s\t%s\t%d\n", delivery.ReceivedAt.Time.Format(time.RFC3339),
+			delivery.DeliveryID, delivery.EventType, status)
+	}
+
+	fmt.Printf("Replayed %d deliveries, %d failed\n", replayed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d deliveries failed on replay", failed)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+// Package schemas holds the JSON Schemas of the events Alfred writes to the
+// Valkey streams. They are served under /api/schemas so that DevPool,
+// Gravemind and Pulse can validate what they read.
+package schemas
+
+import "embed"
+
+//go:embed *.json
+var FS embed.FS
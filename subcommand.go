@@ -0,0 +1,23 @@
+package main
+
+import "fmt"
+
+const usage = `Usage: alfred [command] [flags]
+
+Starts the webhook server when no command is given.
+
+Commands:
+  replay      Feed archived webhook deliveries back through the handlers
+  deadletter  List, inspect, retry or discard dead letters`
+
+func runSubcommand(name string, args []string) error {
+	switch name {
+	case "replay":
+		return runReplay(args)
+	case "deadletter":
+		return runDeadLetter(args)
+	default:
+		fmt.Println(usage)
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/IAmRiteshKoushik/alfred/cmd"
+	db "github.com/IAmRiteshKoushik/alfred/db/gen"
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+)
+
+// Kinds of dead letters
+const (
+	DeadPublish  = "publish"
+	DeadDelivery = "delivery"
+)
+
+// States of a dead letter
+const (
+	DeadOpen      = "open"
+	DeadRetried   = "retried"
+	DeadDiscarded = "discarded"
+)
+
+// DeadLetter is the payload announced on the dead-letter-stream. The original
+// payload is left out as it can be large, it is kept in the dead_letters table.
+type DeadLetter struct {
+	ID        int64  `json:"id"`
+	Kind      string `json:"kind"`
+	Source    string `json:"source"`
+	Reference string `json:"reference"`
+	Error     string `json:"error"`
+	Attempts  int    `json:"attempts"`
+}
+
+// addDeadLetter records something that was given up on. It runs inside the
+// caller's transaction so that the failure is never lost between the two.
+func addDeadLetter(ctx context.Context, tx db.DBTX, kind string, source string,
+	reference string, payload string, attempts int, failure error) (DeadLetter, error) {
+
+	letter := DeadLetter{
+		Kind:      kind,
+		Source:    source,
+		Reference: reference,
+		Error:     failure.Error(),
+		Attempts:  attempts,
+	}
+	id, err := db.New().AddDeadLetterQuery(ctx, tx, db.AddDeadLetterQueryParams{
+		Kind:      kind,
+		Source:    source,
+		Reference: reference,
+		Payload:   payload,
+		Error:     letter.Error,
+		Attempts:  int32(attempts),
+	})
+	if err != nil {
+		return DeadLetter{}, fmt.Errorf("failed to add dead letter: %w", err)
+	}
+	letter.ID = id
+	return letter, nil
+}
+
+// announceDeadLetter publishes the dead letter for alerting. This is best
+// effort, Valkey being down is a common reason for ending up here and the
+// table remains the record of what failed.
+func announceDeadLetter(letter DeadLetter) {
+	pkg.Log.With(map[string]any{
+		"kind":          letter.Kind,
+		"reference":     letter.Reference,
+		"source":        letter.Source,
+		pkg.LogAttempts: letter.Attempts,
+		pkg.LogError:    letter.Error,
+	}).SetupWarn("[DEADLETTER]: Given up")
+
+	ctx := context.Background()
+	event, err := pkg.NewEnvelope(ctx, pkg.DeadLetterEvent, "", letter)
+	if err != nil {
+		pkg.Log.SetupFail("[DEADLETTER]: Failed to build dead letter event", err)
+		return
+	}
+	if err := cmd.AddToStream(ctx, pkg.Valkey, pkg.DeadLetters, event); err != nil {
+		pkg.Log.SetupFail("[DEADLETTER]: Failed to announce dead letter", err)
+	}
+}
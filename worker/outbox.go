@@ -0,0 +1,192 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/IAmRiteshKoushik/alfred/cmd"
+	db "github.com/IAmRiteshKoushik/alfred/db/gen"
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var notifyOutbox = make(chan struct{}, 1)
+
+// NotifyOutbox wakes up the relay after a transaction with outbox entries has
+// committed so that they are published without waiting for the next poll.
+func NotifyOutbox() {
+	select {
+	case notifyOutbox <- struct{}{}:
+	default:
+	}
+}
+
+type OutboxRelay struct {
+	PollInterval time.Duration
+	BatchSize    int32
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+}
+
+func NewOutboxRelay() *OutboxRelay {
+	return &OutboxRelay{
+		PollInterval: time.Second,
+		BatchSize:    100,
+		MaxAttempts:  cmd.AppConfig.OutboxMaxAttempts,
+		BaseBackoff:  cmd.AppConfig.QueueBaseBackoff,
+		MaxBackoff:   cmd.AppConfig.QueueMaxBackoff,
+	}
+}
+
+// Run publishes committed outbox entries to Valkey in the order they were
+// written until ctx is cancelled. An entry is only marked as published after
+// Valkey has accepted it, so every entry is delivered at least once. Stream
+// consumers must tolerate duplicates, sorted-set increments are guarded by a
+// marker key so they are applied exactly once.
+//
+// After a failure the relay backs off and ignores notifications until the
+// failed entry goes through or is moved to the dead letters.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	failures := 0
+	for {
+		wait := r.PollInterval
+		wake := notifyOutbox
+		for ctx.Err() == nil {
+			n, err := r.relay(ctx)
+			if err != nil {
+				failures++
+				wait = backoff(r.BaseBackoff, r.MaxBackoff, failures)
+				wake = nil
+				pkg.Log.With(map[string]any{"retry_in": wait.String()}).
+					SetupFail("[OUTBOX]: Relay stopped on failed entry", err)
+				break
+			}
+			failures = 0
+			if n < int(r.BatchSize) {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			pkg.Log.SetupInfo("[DEACTIVE]: Outbox relay stopped.")
+			return
+		case <-wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// relay publishes one batch and returns how many entries were published. It
+// stops at the first failure so that later entries never overtake it, unless
+// the entry has used up its attempts. It is then moved to the dead letters
+// and the entries behind it go ahead.
+func (r *OutboxRelay) relay(ctx context.Context) (int, error) {
+	// A batch that has started publishing is finished even when shutting
+	// down, otherwise the published entries would be relayed again.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+
+	tx, err := cmd.BeginTx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	q := db.New()
+	entries, err := q.GetPendingOutboxEntriesQuery(ctx, tx, r.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch outbox entries: %w", err)
+	}
+
+	published := 0
+	var failure error
+	var letters []DeadLetter
+	for _, entry := range entries {
+		if failure = publish(ctx, entry); failure != nil {
+			attempts := int(entry.Attempts) + 1
+			if attempts >= r.MaxAttempts {
+				letter, err := r.bury(ctx, tx, entry, attempts, failure)
+				if err != nil {
+					return 0, err
+				}
+				letters = append(letters, letter)
+				failure = nil
+				continue
+			}
+			err = q.MarkOutboxEntryFailedQuery(ctx, tx, db.MarkOutboxEntryFailedQueryParams{
+				LastError: pgtype.Text{String: failure.Error(), Valid: true},
+				ID:        entry.ID,
+			})
+			if err != nil {
+				return 0, fmt.Errorf("failed to mark outbox entry %d: %w", entry.ID, err)
+			}
+			failure = fmt.Errorf("outbox entry %d: %w", entry.ID, failure)
+			break
+		}
+		if err = q.MarkOutboxEntryPublishedQuery(ctx, tx, entry.ID); err != nil {
+			return 0, fmt.Errorf("failed to mark outbox entry %d: %w", entry.ID, err)
+		}
+		published++
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	for _, letter := range letters {
+		announceDeadLetter(letter)
+	}
+	return published, failure
+}
+
+// bury takes an entry out of the relay and records it as a dead letter
+func (r *OutboxRelay) bury(ctx context.Context, tx pgx.Tx, entry db.OutboxEntry,
+	attempts int, failure error) (DeadLetter, error) {
+
+	q := db.New()
+	err := q.MarkOutboxEntryDeadQuery(ctx, tx, db.MarkOutboxEntryDeadQueryParams{
+		LastError: pgtype.Text{String: failure.Error(), Valid: true},
+		ID:        entry.ID,
+	})
+	if err != nil {
+		return DeadLetter{}, fmt.Errorf("failed to bury outbox entry %d: %w", entry.ID, err)
+	}
+	payload := entry.Value
+	if entry.Command == pkg.OutboxSortedSet {
+		payload = fmt.Sprintf("%s %g", entry.Value, entry.Increment)
+	}
+	return addDeadLetter(ctx, tx, DeadPublish, entry.Key,
+		strconv.FormatInt(entry.ID, 10), payload, attempts, failure)
+}
+
+// publish applies one entry to Valkey. Stream entries continue the trace of
+// the handler that wrote them.
+func publish(ctx context.Context, entry db.OutboxEntry) error {
+	switch entry.Command {
+	case pkg.OutboxStream:
+		var event pkg.Envelope
+		if err := json.Unmarshal([]byte(entry.Value), &event); err != nil {
+			return fmt.Errorf("failed to unmarshal envelope: %w", err)
+		}
+		ctx, span := pkg.Tracer.Start(pkg.ContextWithTraceparent(ctx, event.Traceparent),
+			"outbox publish "+entry.Key)
+		defer span.End()
+		err := cmd.AddToStream(ctx, pkg.Valkey, entry.Key, event)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	case pkg.OutboxSortedSet:
+		marker := pkg.OutboxKeyPrefix + strconv.FormatInt(entry.ID, 10)
+		return cmd.UpdateLeaderboardOnce(ctx, pkg.Valkey, entry.Key, entry.Value,
+			entry.Increment, marker)
+	default:
+		return fmt.Errorf("unknown outbox command %s", entry.Command)
+	}
+}
@@ -0,0 +1,267 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/IAmRiteshKoushik/alfred/cmd"
+	db "github.com/IAmRiteshKoushik/alfred/db/gen"
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Outcomes of a delivery as it moves through the queue. Rejected deliveries
+// were answered with a 4xx, such as an event about someone who is not a
+// participant, and keep the status code. Only Failed ones are dead letters.
+// Duplicates are repeats of a delivery already received, they are archived
+// but never queued.
+const (
+	Queued     = "queued"
+	Processing = "processing"
+	Processed  = "processed"
+	Rejected   = "rejected"
+	Failed     = "failed"
+	Duplicate  = "duplicate"
+)
+
+// ProcessFunc runs a single delivery through the webhook handlers and returns
+// the HTTP status the handlers responded with.
+type ProcessFunc func(delivery db.WebhookDelivery) (int, error)
+
+var notifyQueue = make(chan struct{}, 1)
+
+// NotifyQueue wakes up the queue after a new delivery has been persisted so
+// that it is picked up without waiting for the next poll.
+func NotifyQueue() {
+	select {
+	case notifyQueue <- struct{}{}:
+	default:
+	}
+}
+
+type DeliveryQueue struct {
+	Process      ProcessFunc
+	Workers      int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration
+}
+
+func NewDeliveryQueue(process ProcessFunc) *DeliveryQueue {
+	return &DeliveryQueue{
+		Process:      process,
+		Workers:      cmd.AppConfig.QueueWorkers,
+		MaxAttempts:  cmd.AppConfig.QueueMaxAttempts,
+		BaseBackoff:  cmd.AppConfig.QueueBaseBackoff,
+		MaxBackoff:   cmd.AppConfig.QueueMaxBackoff,
+		PollInterval: cmd.AppConfig.QueuePollInterval,
+	}
+}
+
+// Run claims queued deliveries in order of arrival and hands them to a fixed
+// set of lanes. Every ordering key (issue or pull request url) always maps to
+// the same lane and each lane works serially, so events for one issue are
+// never processed out of order or concurrently. Run blocks until ctx is
+// cancelled and every lane has finished its current delivery.
+func (q *DeliveryQueue) Run(ctx context.Context) {
+	// Anything left in processing belonged to a previous run that did not
+	// shut down cleanly, it is safe to pick those up again.
+	if n, err := db.New().RequeueProcessingDeliveriesQuery(ctx, cmd.DBPool); err != nil {
+		pkg.Log.SetupFail("[QUEUE]: Failed to requeue interrupted deliveries", err)
+	} else if n > 0 {
+		pkg.Log.With(map[string]any{pkg.LogCount: n}).
+			SetupWarn("[QUEUE]: Requeued interrupted deliveries")
+	}
+
+	var wg sync.WaitGroup
+	lanes := make([]chan db.WebhookDelivery, q.Workers)
+	for i := range lanes {
+		lanes[i] = make(chan db.WebhookDelivery, 64)
+		wg.Add(1)
+		go func(lane <-chan db.WebhookDelivery) {
+			defer wg.Done()
+			for delivery := range lane {
+				q.work(ctx, delivery)
+			}
+		}(lanes[i])
+	}
+
+	ticker := time.NewTicker(q.PollInterval)
+	defer ticker.Stop()
+	for {
+		q.dispatch(ctx, lanes)
+		select {
+		case <-ctx.Done():
+			for _, lane := range lanes {
+				close(lane)
+			}
+			wg.Wait()
+			pkg.Log.SetupInfo("[DEACTIVE]: Delivery queue drained.")
+			return
+		case <-notifyQueue:
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch claims every queued delivery and routes it to its lane
+func (q *DeliveryQueue) dispatch(ctx context.Context, lanes []chan db.WebhookDelivery) {
+	for ctx.Err() == nil {
+		claimCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		batch, err := db.New().ClaimQueuedDeliveriesQuery(claimCtx, cmd.DBPool, 50)
+		cancel()
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				pkg.Log.SetupFail("[QUEUE]: Failed to claim queued deliveries", err)
+			}
+			return
+		}
+		if len(batch) == 0 {
+			return
+		}
+
+		// RETURNING does not preserve the order of the sub-select
+		slices.SortFunc(batch, func(a, b db.WebhookDelivery) int {
+			return a.ReceivedAt.Time.Compare(b.ReceivedAt.Time)
+		})
+		for _, delivery := range batch {
+			lanes[laneFor(delivery.OrderingKey, len(lanes))] <- delivery
+		}
+	}
+}
+
+func laneFor(key string, lanes int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(lanes))
+}
+
+// work processes one delivery, retrying server side failures with exponential
+// backoff. Client errors (4xx) are not retried as the payload will not change,
+// the delivery is recorded as rejected instead.
+func (q *DeliveryQueue) work(ctx context.Context, delivery db.WebhookDelivery) {
+	attempts := int(delivery.Attempts)
+	for {
+		if ctx.Err() != nil {
+			// Shutting down, leave it for the next run
+			q.record(delivery, Queued, 0, attempts, nil)
+			return
+		}
+
+		attempts++
+		status, err := q.Process(delivery)
+		if err == nil && status >= http.StatusInternalServerError {
+			err = fmt.Errorf("handler responded with %d", status)
+		}
+		if err == nil {
+			outcome := Processed
+			if status >= http.StatusBadRequest {
+				outcome = Rejected
+			}
+			q.record(delivery, outcome, status, attempts, nil)
+			return
+		}
+
+		if attempts >= q.MaxAttempts {
+			deliveryLog(delivery, attempts).SetupFail("[QUEUE]: Delivery failed for good", err)
+			q.record(delivery, Failed, status, attempts, err)
+			return
+		}
+
+		wait := backoff(q.BaseBackoff, q.MaxBackoff, attempts)
+		deliveryLog(delivery, attempts).
+			With(map[string]any{"retry_in": wait.String(), pkg.LogError: err.Error()}).
+			SetupWarn("[QUEUE]: Delivery failed, retrying")
+		q.record(delivery, Processing, status, attempts, err)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(wait):
+		}
+	}
+}
+
+// deliveryLog logs about a delivery with its id, event type and attempts
+func deliveryLog(delivery db.WebhookDelivery, attempts int) *pkg.LoggerService {
+	return pkg.Log.With(map[string]any{
+		pkg.LogDeliveryId: delivery.DeliveryID,
+		pkg.LogEventType:  delivery.EventType,
+		pkg.LogAttempts:   attempts,
+	})
+}
+
+// backoff doubles the wait after every attempt, capped at max, with up to 20%
+// jitter so that retries of a batch do not all land together.
+func backoff(base time.Duration, max time.Duration, attempt int) time.Duration {
+	wait := base << (attempt - 1)
+	if wait <= 0 || wait > max {
+		wait = max
+	}
+	jitter := time.Duration(rand.Int64N(int64(wait)/5 + 1))
+	return wait + jitter
+}
+
+// record stores the outcome of an attempt. A delivery that has failed for
+// good is added to the dead letters in the same transaction, and its id is
+// released so that a redelivery from GitHub is processed again.
+func (q *DeliveryQueue) record(delivery db.WebhookDelivery, outcome string,
+	status int, attempts int, failure error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	params := db.UpdateWebhookDeliveryOutcomeQueryParams{
+		Outcome:  outcome,
+		Attempts: int32(attempts),
+		ID:       delivery.ID,
+	}
+	if status != 0 {
+		params.StatusCode = pgtype.Int4{Int32: int32(status), Valid: true}
+	}
+	if failure != nil {
+		params.LastError = pgtype.Text{String: failure.Error(), Valid: true}
+	}
+
+	tx, err := cmd.BeginTx(ctx)
+	if err != nil {
+		deliveryLog(delivery, attempts).SetupFail("[QUEUE]: Failed to record outcome", err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	if err := db.New().UpdateWebhookDeliveryOutcomeQuery(ctx, tx, params); err != nil {
+		deliveryLog(delivery, attempts).SetupFail("[QUEUE]: Failed to record outcome", err)
+		return
+	}
+	var letter DeadLetter
+	if outcome == Failed {
+		letter, err = addDeadLetter(ctx, tx, DeadDelivery, delivery.EventType,
+			delivery.ID.String(), string(delivery.Payload), attempts, failure)
+		if err != nil {
+			deliveryLog(delivery, attempts).SetupFail("[QUEUE]: Failed to dead letter delivery", err)
+			return
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		deliveryLog(delivery, attempts).SetupFail("[QUEUE]: Failed to record outcome", err)
+		return
+	}
+	if outcome == Processed || outcome == Rejected || outcome == Failed {
+		pkg.DeliveriesProcessed.WithLabelValues(delivery.EventType, outcome).Inc()
+	}
+	if outcome == Failed {
+		key := pkg.DeliveryKeyPrefix + delivery.DeliveryID
+		if err := cmd.ForgetDelivery(pkg.Valkey, key); err != nil {
+			deliveryLog(delivery, attempts).SetupFail("[QUEUE]: Failed to release delivery", err)
+		}
+		announceDeadLetter(letter)
+	}
+}
@@ -0,0 +1,172 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/IAmRiteshKoushik/alfred/cmd"
+	db "github.com/IAmRiteshKoushik/alfred/db/gen"
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/jackc/pgx/v5"
+)
+
+// ClaimFunc queues the events for a claim inside the sweeper's transaction
+type ClaimFunc func(ctx context.Context, tx pgx.Tx, claim db.IssueClaim) error
+
+// PendingClaimFunc passes on an issue whose pending claim expired inside the
+// sweeper's transaction
+type PendingClaimFunc func(ctx context.Context, tx pgx.Tx, claim db.PendingClaim) error
+
+type ClaimSweeper struct {
+	Release      ClaimFunc
+	Remind       ClaimFunc
+	Lapse        PendingClaimFunc
+	Interval     time.Duration
+	RemindBefore time.Duration
+	BatchSize    int32
+}
+
+func NewClaimSweeper(release ClaimFunc, remind ClaimFunc, lapse PendingClaimFunc) *ClaimSweeper {
+	return &ClaimSweeper{
+		Release:      release,
+		Remind:       remind,
+		Lapse:        lapse,
+		Interval:     cmd.AppConfig.ClaimSweepInterval,
+		RemindBefore: cmd.AppConfig.ClaimReminderBefore,
+		BatchSize:    100,
+	}
+}
+
+// Run releases expired claims and pending claims, and sends out reminders
+// for the claims about to expire once per interval until ctx is cancelled.
+// A claim is removed in the same transaction that queues its events, so the
+// events go out if and only if the claim is gone.
+func (s *ClaimSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		n, err := s.drain(ctx, s.releaseBatch)
+		if err != nil {
+			pkg.Log.SetupFail("[SWEEP]: Failed to release expired claims", err)
+		}
+		if n > 0 {
+			pkg.Log.With(map[string]any{pkg.LogCount: n}).
+				SetupInfo("[SWEEP]: Released expired claims")
+		}
+		n, err = s.drain(ctx, s.lapseBatch)
+		if err != nil {
+			pkg.Log.SetupFail("[SWEEP]: Failed to release expired pending claims", err)
+		}
+		if n > 0 {
+			pkg.Log.With(map[string]any{pkg.LogCount: n}).
+				SetupInfo("[SWEEP]: Released expired pending claims")
+		}
+		if s.RemindBefore > 0 {
+			n, err = s.drain(ctx, s.remindBatch)
+			if err != nil {
+				pkg.Log.SetupFail("[SWEEP]: Failed to send claim reminders", err)
+			}
+			if n > 0 {
+				pkg.Log.With(map[string]any{pkg.LogCount: n}).
+					SetupInfo("[SWEEP]: Reminded participants")
+			}
+		}
+		select {
+		case <-ctx.Done():
+			pkg.Log.SetupInfo("[DEACTIVE]: Claim sweeper stopped.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain runs batches until one comes back short and returns how many claims
+// were handled in total
+func (s *ClaimSweeper) drain(ctx context.Context,
+	batch func(context.Context) (int, error)) (int, error) {
+
+	total := 0
+	for ctx.Err() == nil {
+		n, err := batch(ctx)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < int(s.BatchSize) {
+			break
+		}
+	}
+	return total, nil
+}
+
+func (s *ClaimSweeper) releaseBatch(ctx context.Context) (int, error) {
+	return batch(ctx, func(ctx context.Context, tx pgx.Tx) ([]db.IssueClaim, error) {
+		return db.New().GetExpiredClaimsQuery(ctx, tx, s.BatchSize)
+	}, func(ctx context.Context, tx pgx.Tx, claim db.IssueClaim) error {
+		if err := db.New().DeleteClaimQuery(ctx, tx, claim.ID); err != nil {
+			return fmt.Errorf("failed to delete claim %s: %w", claim.ID, err)
+		}
+		return s.Release(ctx, tx, claim)
+	})
+}
+
+func (s *ClaimSweeper) remindBatch(ctx context.Context) (int, error) {
+	return batch(ctx, func(ctx context.Context, tx pgx.Tx) ([]db.IssueClaim, error) {
+		return db.New().GetClaimsDueReminderQuery(ctx, tx, db.GetClaimsDueReminderQueryParams{
+			RemindBefore: s.RemindBefore.Seconds(),
+			BatchSize:    s.BatchSize,
+		})
+	}, func(ctx context.Context, tx pgx.Tx, claim db.IssueClaim) error {
+		if err := db.New().MarkClaimRemindedQuery(ctx, tx, claim.ID); err != nil {
+			return fmt.Errorf("failed to mark claim %s as reminded: %w", claim.ID, err)
+		}
+		return s.Remind(ctx, tx, claim)
+	})
+}
+
+// lapseBatch removes pending claims GitHub never reported an assignment for
+// and passes their issues on to the waitlist
+func (s *ClaimSweeper) lapseBatch(ctx context.Context) (int, error) {
+	return batch(ctx, func(ctx context.Context, tx pgx.Tx) ([]db.PendingClaim, error) {
+		return db.New().GetExpiredPendingClaimsQuery(ctx, tx, s.BatchSize)
+	}, func(ctx context.Context, tx pgx.Tx, claim db.PendingClaim) error {
+		if err := db.New().ClearPendingClaimQuery(ctx, tx, claim.IssueUrl); err != nil {
+			return fmt.Errorf("failed to delete pending claim %d: %w", claim.ID, err)
+		}
+		return s.Lapse(ctx, tx, claim)
+	})
+}
+
+// batch applies fn to the claims returned by fetch in one transaction
+func batch[T any](ctx context.Context,
+	fetch func(context.Context, pgx.Tx) ([]T, error),
+	fn func(context.Context, pgx.Tx, T) error) (int, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := cmd.BeginTx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	claims, err := fetch(ctx, tx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch claims: %w", err)
+	}
+	for _, claim := range claims {
+		if err = fn(ctx, tx, claim); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if len(claims) > 0 {
+		NotifyOutbox()
+	}
+	return len(claims), nil
+}
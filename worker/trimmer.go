@@ -0,0 +1,181 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IAmRiteshKoushik/alfred/bootstrap"
+	"github.com/IAmRiteshKoushik/alfred/cmd"
+	"github.com/IAmRiteshKoushik/alfred/pkg"
+	"github.com/redis/go-redis/v9"
+)
+
+type StreamTrimmer struct {
+	Interval time.Duration
+	Streams  []string
+}
+
+func NewStreamTrimmer() *StreamTrimmer {
+	var streams []string
+	for _, s := range bootstrap.Structures {
+		if s.Type == "stream" {
+			streams = append(streams, s.Name)
+		}
+	}
+	return &StreamTrimmer{
+		Interval: cmd.AppConfig.RetentionInterval,
+		Streams:  streams,
+	}
+}
+
+// Run trims every stream to its retention policy once per interval until ctx
+// is cancelled. The first pass runs straight away so that writes can start
+// trimming as soon as possible.
+func (t *StreamTrimmer) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+	for {
+		for _, stream := range t.Streams {
+			if err := t.trim(ctx, stream); err != nil {
+				pkg.Log.With(map[string]any{pkg.LogStream: stream}).
+					SetupFail("[TRIM]: Failed to trim stream", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			pkg.Log.SetupInfo("[DEACTIVE]: Stream trimmer stopped.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *StreamTrimmer) trim(ctx context.Context, stream string) error {
+	policy := cmd.AppConfig.RetentionFor(stream)
+	if policy.MaxLen <= 0 && policy.MaxAge <= 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client := pkg.Valkey
+
+	// Oldest id the policy wants to keep
+	var cutoffs []string
+	if policy.MaxAge > 0 {
+		cutoffs = append(cutoffs,
+			fmt.Sprintf("%d-0", time.Now().Add(-policy.MaxAge).UnixMilli()))
+	}
+	if policy.MaxLen > 0 {
+		length, err := client.XLen(ctx, stream).Result()
+		if err != nil {
+			return fmt.Errorf("failed to read length of %s: %w", stream, err)
+		}
+		if length > policy.MaxLen {
+			// Only the excess is read, the entry after it is the oldest
+			// one to keep
+			oldest, err := client.XRangeN(ctx, stream, "-", "+", length-policy.MaxLen+1).Result()
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", stream, err)
+			}
+			if len(oldest) > 0 {
+				cutoffs = append(cutoffs, oldest[len(oldest)-1].ID)
+			}
+		}
+	}
+	if len(cutoffs) == 0 {
+		return nil
+	}
+
+	// Oldest id some consumer group still needs: anything it has not been
+	// delivered yet, or has been delivered but not acknowledged.
+	groups, err := client.XInfoGroups(ctx, stream).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read consumer groups of %s: %w", stream, err)
+	}
+	var floors []string
+	for _, group := range groups {
+		floors = append(floors, group.LastDeliveredID)
+		if group.Pending == 0 {
+			continue
+		}
+		pending, err := client.XPending(ctx, stream, group.Name).Result()
+		if err != nil && err != redis.Nil {
+			return fmt.Errorf("failed to read pending entries of %s: %w", stream, err)
+		}
+		if pending != nil && pending.Lower != "" {
+			floors = append(floors, pending.Lower)
+		}
+	}
+	minId := trimBoundary(cutoffs, floors)
+
+	trimmed, err := client.XTrimMinID(ctx, stream, minId).Result()
+	if err != nil {
+		return fmt.Errorf("failed to trim %s: %w", stream, err)
+	}
+	cmd.SetStreamMinId(stream, minId)
+	if trimmed > 0 {
+		pkg.Log.With(map[string]any{pkg.LogStream: stream, pkg.LogCount: trimmed, "min_id": minId}).
+			SetupInfo("[TRIM]: Removed old entries")
+	}
+	return nil
+}
+
+// trimBoundary keeps what the strictest limit of the policy allows, but never
+// goes past the oldest entry a consumer group still needs.
+func trimBoundary(cutoffs []string, floors []string) string {
+	policy := maxStreamId(cutoffs...)
+	if policy == "" {
+		return ""
+	}
+	return minStreamId(append(floors, policy)...)
+}
+
+// compareStreamIds orders two stream ids of the form <ms>-<seq>
+func compareStreamIds(a, b string) int {
+	aMs, aSeq := splitStreamId(a)
+	bMs, bSeq := splitStreamId(b)
+	switch {
+	case aMs != bMs:
+		if aMs < bMs {
+			return -1
+		}
+		return 1
+	case aSeq != bSeq:
+		if aSeq < bSeq {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func splitStreamId(id string) (uint64, uint64) {
+	msPart, seqPart, _ := strings.Cut(id, "-")
+	ms, _ := strconv.ParseUint(msPart, 10, 64)
+	seq, _ := strconv.ParseUint(seqPart, 10, 64)
+	return ms, seq
+}
+
+func minStreamId(ids ...string) string {
+	lowest := ""
+	for _, id := range ids {
+		if lowest == "" || compareStreamIds(id, lowest) < 0 {
+			lowest = id
+		}
+	}
+	return lowest
+}
+
+func maxStreamId(ids ...string) string {
+	highest := ""
+	for _, id := range ids {
+		if highest == "" || compareStreamIds(id, highest) > 0 {
+			highest = id
+		}
+	}
+	return highest
+}
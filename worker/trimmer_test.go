@@ -0,0 +1,70 @@
+package worker
+
+import "testing"
+
+func TestCompareStreamIds(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1-0", "1-0", 0},
+		{"1-0", "2-0", -1},
+		{"2-0", "1-5", 1},
+		{"1-2", "1-10", -1},
+		{"9-0", "10-0", -1},
+		{"1718000000000-3", "1718000000000-3", 0},
+	}
+	for _, tt := range tests {
+		if got := compareStreamIds(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareStreamIds(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestTrimBoundary(t *testing.T) {
+	tests := []struct {
+		name     string
+		cutoffs  []string
+		floors   []string
+		expected string
+	}{
+		{
+			name:     "policy wins when groups are ahead",
+			cutoffs:  []string{"100-0", "150-0"},
+			floors:   []string{"200-0", "300-1"},
+			expected: "150-0",
+		},
+		{
+			name:     "never past a lagging group",
+			cutoffs:  []string{"500-0"},
+			floors:   []string{"900-0", "120-4"},
+			expected: "120-4",
+		},
+		{
+			name:     "fresh group keeps everything",
+			cutoffs:  []string{"500-0"},
+			floors:   []string{"0-0"},
+			expected: "0-0",
+		},
+		{
+			name:     "no groups",
+			cutoffs:  []string{"500-0", "20-0"},
+			floors:   nil,
+			expected: "500-0",
+		},
+		{
+			name:     "no policy",
+			cutoffs:  nil,
+			floors:   []string{"10-0"},
+			expected: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := trimBoundary(tt.cutoffs, tt.floors)
+			if got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}